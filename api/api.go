@@ -46,6 +46,7 @@ type endpoints struct {
 	AddUnreadMark               AddUnreadMarkFn
 	AddUserToGroup              AddUserToGroupFn
 	BlockUser                   BlockUserFn
+	Broadcast                   BroadcastFn
 	ChangeGroupOwner            ChangeGroupOwnerFn
 	CreateAutoReply             CreateAutoReplyFn
 	CreateCatalog               CreateCatalogFn
@@ -60,6 +61,7 @@ type endpoints struct {
 	DeleteGroup                 DeleteGroupFn
 	DeleteMessage               DeleteMessageFn
 	DisableGroupLink            DisableGroupLinkFn
+	DownloadAttachment          DownloadAttachmentFn
 	EnableGroupLink             EnableGroupLinkFn
 	FindUser                    FindUserFn
 	ForwardMessage              ForwardMessageFn
@@ -116,6 +118,7 @@ type endpoints struct {
 	RemoveUnreadMark            RemoveUnreadMarkFn
 	RemoveUserFromGroup         RemoveUserFromGroupFn
 	ResetHiddenChatPIN          ResetHiddenChatPINFn
+	ResumeUploadAttachment      ResumeUploadAttachmentFn
 	ReuseAvatar                 ReuseAvatarFn
 	ReviewPendingMemberRequest  ReviewPendingMemberRequestFn
 	SendBankCard                SendBankCardFn
@@ -124,12 +127,14 @@ type endpoints struct {
 	SendFriendRequest           SendFriendRequestFn
 	SendGIF                     SendGIFFn
 	SendLink                    SendLinkFn
+	SendLocation                SendLocationFn
 	SendMessage                 SendMessageFn
 	SendReport                  SendReportFn
 	SendSeenEvent               SendSeenEventFn
 	SendSticker                 SendStickerFn
 	SendTypingEvent             SendTypingEventFn
 	SendVideo                   SendVideoFn
+	SendVideoFile               SendVideoFileFn
 	SendVoice                   SendVoiceFn
 	SetHiddenChat               SetHiddenChatFn
 	SetMute                     SetMuteFn
@@ -177,6 +182,7 @@ func (a *api) initEndpoints() error {
 		bind(a.sc, a, &a.e.AddUnreadMark, addUnreadMarkFactory),
 		bind(a.sc, a, &a.e.AddUserToGroup, addUserToGroupFactory),
 		bind(a.sc, a, &a.e.BlockUser, blockUserFactory),
+		bind(a.sc, a, &a.e.Broadcast, broadcastFactory),
 		bind(a.sc, a, &a.e.ChangeGroupOwner, changeGroupOwnerFactory),
 		bind(a.sc, a, &a.e.CreateAutoReply, createAutoReplyFactory),
 		bind(a.sc, a, &a.e.CreateCatalog, createCatalogFactory),
@@ -191,6 +197,7 @@ func (a *api) initEndpoints() error {
 		bind(a.sc, a, &a.e.DeleteGroup, deleteGroupFactory),
 		bind(a.sc, a, &a.e.DeleteMessage, deleteMessageFactory),
 		bind(a.sc, a, &a.e.DisableGroupLink, disableGroupLinkFactory),
+		bind(a.sc, a, &a.e.DownloadAttachment, downloadAttachmentFactory),
 		bind(a.sc, a, &a.e.EnableGroupLink, enableGroupLinkFactory),
 		bind(a.sc, a, &a.e.FindUser, findUserFactory),
 		bind(a.sc, a, &a.e.ForwardMessage, forwardMessageFactory),
@@ -247,6 +254,7 @@ func (a *api) initEndpoints() error {
 		bind(a.sc, a, &a.e.RemoveUnreadMark, removeUnreadMarkFactory),
 		bind(a.sc, a, &a.e.RemoveUserFromGroup, removeUserFromGroupFactory),
 		bind(a.sc, a, &a.e.ResetHiddenChatPIN, resetHiddenChatPINFactory),
+		bind(a.sc, a, &a.e.ResumeUploadAttachment, resumeUploadAttachmentFactory),
 		bind(a.sc, a, &a.e.ReuseAvatar, reuseAvatarFactory),
 		bind(a.sc, a, &a.e.ReviewPendingMemberRequest, reviewPendingMemberRequestFactory),
 		bind(a.sc, a, &a.e.SendBankCard, sendBankCardFactory),
@@ -255,12 +263,14 @@ func (a *api) initEndpoints() error {
 		bind(a.sc, a, &a.e.SendFriendRequest, sendFriendRequestFactory),
 		bind(a.sc, a, &a.e.SendGIF, sendGIFFactory),
 		bind(a.sc, a, &a.e.SendLink, sendLinkFactory),
+		bind(a.sc, a, &a.e.SendLocation, sendLocationFactory),
 		bind(a.sc, a, &a.e.SendMessage, sendMessageFactory),
 		bind(a.sc, a, &a.e.SendReport, sendReportFactory),
 		bind(a.sc, a, &a.e.SendSeenEvent, sendSeenEventFactory),
 		bind(a.sc, a, &a.e.SendSticker, sendStickerFactory),
 		bind(a.sc, a, &a.e.SendTypingEvent, sendTypingEventFactory),
 		bind(a.sc, a, &a.e.SendVideo, sendVideoFactory),
+		bind(a.sc, a, &a.e.SendVideoFile, sendVideoFileFactory),
 		bind(a.sc, a, &a.e.SendVoice, sendVoiceFactory),
 		bind(a.sc, a, &a.e.SetHiddenChat, setHiddenChatFactory),
 		bind(a.sc, a, &a.e.SetMute, setMuteFactory),
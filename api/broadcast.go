@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Amrakk/zcago/errs"
+	"github.com/Amrakk/zcago/model"
+	"github.com/Amrakk/zcago/session"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	DefaultBroadcastConcurrency = 4
+	DefaultBroadcastInterval    = 200 * time.Millisecond
+)
+
+var ErrBroadcastTargetsEmpty = errs.NewZCA("broadcast targets cannot be empty", "api.Broadcast")
+
+type (
+	BroadcastTarget struct {
+		ThreadID   string
+		ThreadType model.ThreadType
+	}
+	BroadcastOptions struct {
+		// Concurrency is the maximum number of in-flight sends, defaults to DefaultBroadcastConcurrency.
+		Concurrency int
+		// Interval is the minimum delay between two sends, defaults to DefaultBroadcastInterval.
+		// Sends also wait for the session send rate, see zcago.WithSendInterval.
+		Interval time.Duration
+
+		// Checkpoint from a previous run; targets already recorded as sent are skipped.
+		// When nil, a new checkpoint is created and returned in the response.
+		Checkpoint *BroadcastCheckpoint
+		// OnResult is called sequentially after each target is processed,
+		// it is safe to persist the checkpoint from within the callback.
+		OnResult func(result BroadcastResult, checkpoint *BroadcastCheckpoint)
+	}
+	BroadcastCheckpoint struct {
+		Sent map[string]string `json:"sent"` // Target key -> msgId
+	}
+	BroadcastResult struct {
+		Target   BroadcastTarget
+		MsgID    string               // ID of the text message, or of the first attachment
+		Response *SendMessageResponse // nil when skipped or failed
+		Skipped  bool                 // Already sent according to the checkpoint
+		Err      error
+	}
+
+	BroadcastResponse struct {
+		Results    []BroadcastResult // Same order as the targets
+		Checkpoint *BroadcastCheckpoint
+	}
+	BroadcastFn = func(ctx context.Context, targets []BroadcastTarget, message MessageContent, options *BroadcastOptions) (*BroadcastResponse, error)
+)
+
+func (a *api) Broadcast(ctx context.Context, targets []BroadcastTarget, message MessageContent, options *BroadcastOptions) (*BroadcastResponse, error) {
+	return a.e.Broadcast(ctx, targets, message, options)
+}
+
+var broadcastFactory = apiFactory[*BroadcastResponse, BroadcastFn]()(
+	func(a *api, sc session.Context, u factoryUtils[*BroadcastResponse]) (BroadcastFn, error) {
+		return func(ctx context.Context, targets []BroadcastTarget, message MessageContent, options *BroadcastOptions) (*BroadcastResponse, error) {
+			if len(targets) == 0 {
+				return nil, ErrBroadcastTargetsEmpty
+			}
+			if len(message.Msg) == 0 && len(message.Attachments) == 0 && len(message.UploadedAttachments) == 0 {
+				return nil, ErrMessageContentEmpty
+			}
+			if len(message.Msg) == 0 && message.Quote != nil {
+				return nil, ErrQuoteWithoutText
+			}
+
+			opts := BroadcastOptions{
+				Concurrency: DefaultBroadcastConcurrency,
+				Interval:    DefaultBroadcastInterval,
+			}
+			if options != nil {
+				if options.Concurrency > 0 {
+					opts.Concurrency = options.Concurrency
+				}
+				if options.Interval > 0 {
+					opts.Interval = options.Interval
+				}
+				opts.Checkpoint = options.Checkpoint
+				opts.OnResult = options.OnResult
+			}
+
+			checkpoint := opts.Checkpoint
+			if checkpoint == nil {
+				checkpoint = &BroadcastCheckpoint{}
+			}
+			if checkpoint.Sent == nil {
+				checkpoint.Sent = make(map[string]string, len(targets))
+			}
+
+			res := &BroadcastResponse{
+				Results:    make([]BroadcastResult, len(targets)),
+				Checkpoint: checkpoint,
+			}
+
+			pending := make([]int, 0, len(targets))
+			for i, t := range targets {
+				res.Results[i].Target = t
+				if msgID, ok := checkpoint.Sent[t.Key()]; ok {
+					res.Results[i].MsgID = msgID
+					res.Results[i].Skipped = true
+					continue
+				}
+				pending = append(pending, i)
+			}
+			if len(pending) == 0 {
+				return res, nil
+			}
+
+			// Upload local attachments once per thread type, as uploads are bound to it,
+			// then reuse the uploaded files for every target of that type
+			messages := make(map[model.ThreadType]MessageContent, 2)
+			for _, i := range pending {
+				t := targets[i]
+				if _, ok := messages[t.ThreadType]; ok {
+					continue
+				}
+
+				m := message
+				if len(message.Attachments) > 0 {
+					uploaded, err := a.UploadAttachment(ctx, t.ThreadID, t.ThreadType, message.Attachments...)
+					if err != nil {
+						return nil, errs.WrapZCA("failed to upload attachments", "api.Broadcast", err)
+					}
+					m.UploadedAttachments = append(append([]UploadAttachment(nil), message.UploadedAttachments...), uploaded...)
+					m.Attachments = nil
+				}
+				messages[t.ThreadType] = m
+			}
+
+			var (
+				mu      sync.Mutex
+				g       errgroup.Group
+				limiter = time.NewTicker(opts.Interval)
+			)
+			defer limiter.Stop()
+			g.SetLimit(opts.Concurrency)
+
+			report := func(i int, r BroadcastResult) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				if r.Err == nil {
+					checkpoint.Sent[r.Target.Key()] = r.MsgID
+				}
+				res.Results[i] = r
+				if opts.OnResult != nil {
+					opts.OnResult(r, checkpoint)
+				}
+			}
+
+			for _, i := range pending {
+				target := targets[i]
+
+				g.Go(func() error {
+					r := BroadcastResult{Target: target}
+
+					select {
+					case <-ctx.Done():
+						r.Err = ctx.Err()
+						report(i, r)
+						return nil
+					case <-limiter.C:
+					}
+
+					resp, err := a.SendMessage(ctx, target.ThreadID, target.ThreadType, messages[target.ThreadType])
+					if err != nil {
+						r.Err = err
+					} else {
+						r.Response = resp
+						r.MsgID = resp.MsgID()
+					}
+
+					report(i, r)
+					return nil
+				})
+			}
+
+			_ = g.Wait()
+			return res, nil
+		}, nil
+	},
+)
+
+// Key returns the identifier of the target used in a BroadcastCheckpoint.
+func (t BroadcastTarget) Key() string {
+	return fmt.Sprintf("%d:%s", t.ThreadType, t.ThreadID)
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/Amrakk/zcago/errs"
+	"github.com/Amrakk/zcago/internal/httpx"
+	"github.com/Amrakk/zcago/model"
+	"github.com/Amrakk/zcago/session"
+)
+
+var (
+	ErrNoAttachment           = errs.NewZCA("message has no downloadable attachment", "api.DownloadAttachment")
+	ErrDownloadSizeMismatch   = errs.NewZCA("downloaded size does not match the attachment metadata", "api.DownloadAttachment")
+	ErrUnsupportedMessageKind = errs.NewZCA("unsupported message kind", "api.DownloadAttachment")
+)
+
+type (
+	DownloadAttachmentResponse struct {
+		URL         string // URL the attachment was downloaded from
+		FileName    string
+		ContentType string
+		Size        int64 // Number of bytes written
+	}
+	DownloadAttachmentFn = func(ctx context.Context, msg model.Message, w io.Writer) (*DownloadAttachmentResponse, error)
+)
+
+func (a *api) DownloadAttachment(ctx context.Context, msg model.Message, w io.Writer) (*DownloadAttachmentResponse, error) {
+	return a.e.DownloadAttachment(ctx, msg, w)
+}
+
+var downloadAttachmentFactory = apiFactory[*DownloadAttachmentResponse, DownloadAttachmentFn]()(
+	func(a *api, sc session.Context, u factoryUtils[*DownloadAttachmentResponse]) (DownloadAttachmentFn, error) {
+		return func(ctx context.Context, msg model.Message, w io.Writer) (*DownloadAttachmentResponse, error) {
+			var data model.TMessage
+			switch m := msg.(type) {
+			case model.UserMessage:
+				data = m.Data
+			case *model.UserMessage:
+				data = m.Data
+			case model.GroupMessage:
+				data = m.Data.TMessage
+			case *model.GroupMessage:
+				data = m.Data.TMessage
+			default:
+				return nil, ErrUnsupportedMessageKind
+			}
+
+			src, ok := pickAttachmentURL(data)
+			if !ok {
+				return nil, ErrNoAttachment
+			}
+
+			h := make(http.Header, 2)
+			h.Set("Accept", "*/*")
+			h.Set("Accept-Encoding", "gzip, deflate")
+
+			resp, err := u.Request(ctx, src.url, &httpx.RequestOptions{
+				Method:  http.MethodGet,
+				Headers: h,
+			})
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+
+			if err := httpx.CheckStatus(resp); err != nil {
+				return nil, errs.WrapZCA("failed to download attachment", "api.DownloadAttachment", err)
+			}
+
+			encoded := resp.Header.Get("Content-Encoding") != ""
+			if src.size > 0 && !encoded && resp.ContentLength >= 0 && resp.ContentLength != src.size {
+				return nil, ErrDownloadSizeMismatch
+			}
+
+			body, err := httpx.DecodeResponse(resp)
+			if err != nil {
+				return nil, errs.WrapZCA("failed to decode attachment", "api.DownloadAttachment", err)
+			}
+			defer body.Close()
+
+			sw := &sniffWriter{w: w}
+			n, err := io.Copy(sw, body)
+			if err != nil {
+				return nil, errs.WrapZCA("failed to download attachment", "api.DownloadAttachment", err)
+			}
+			if src.size > 0 && n != src.size {
+				return nil, ErrDownloadSizeMismatch
+			}
+
+			fileName := src.fileName
+			if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+				fileName = params["filename"]
+			}
+			if fileName == "" {
+				if parsed, err := url.Parse(src.url); err == nil {
+					fileName = path.Base(parsed.Path)
+				}
+			}
+
+			contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+			if contentType == "" || contentType == "application/octet-stream" {
+				if ct := mime.TypeByExtension(path.Ext(fileName)); ct != "" {
+					contentType = ct
+				} else {
+					contentType = http.DetectContentType(sw.head)
+				}
+			}
+
+			return &DownloadAttachmentResponse{
+				URL:         src.url,
+				FileName:    fileName,
+				ContentType: contentType,
+				Size:        n,
+			}, nil
+		}, nil
+	},
+)
+
+type attachmentURL struct {
+	url      string
+	fileName string
+	size     int64 // Expected size, 0 when unknown
+}
+
+// pickAttachmentURL returns the best quality URL of the decoded message content.
+// Photos prefer their HD and original variants, files also carry their name and size.
+func pickAttachmentURL(data model.TMessage) (attachmentURL, bool) {
+	var out attachmentURL
+	switch c := data.Decode().(type) {
+	case model.PhotoContent:
+		out.url = cmp.Or(c.HDURL, c.RawURL, c.URL)
+		if out.url == c.HDURL {
+			out.size = c.HDSize
+		}
+	case model.GIFContent:
+		out.url = c.URL
+	case model.FileContent:
+		out = attachmentURL{url: c.URL, fileName: c.Name, size: c.Size}
+	case model.VoiceContent:
+		out.url = c.URL
+	case model.VideoContent:
+		out.url = c.URL
+	}
+	return out, out.url != ""
+}
+
+// sniffWriter keeps the first bytes written for content type detection.
+type sniffWriter struct {
+	w    io.Writer
+	head []byte
+}
+
+func (s *sniffWriter) Write(p []byte) (int, error) {
+	if rest := 512 - len(s.head); rest > 0 {
+		s.head = append(s.head, p[:min(rest, len(p))]...)
+	}
+	return s.w.Write(p)
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/Amrakk/zcago/errs"
+	"github.com/Amrakk/zcago/internal/jsonx"
+	"github.com/Amrakk/zcago/model"
+	"github.com/Amrakk/zcago/session"
+)
+
+var (
+	ErrUploadStateEmpty     = errs.NewZCA("upload state cannot be empty", "api.ResumeUploadAttachment")
+	ErrUploadSourceMismatch = errs.NewZCA("sources do not match the upload state", "api.ResumeUploadAttachment")
+	ErrUploadSourceMissing  = errs.NewZCA("source of an unfinished file must be provided", "api.ResumeUploadAttachment")
+)
+
+type ResumeUploadAttachmentFn = func(ctx context.Context, state *UploadState, options UploadAttachmentOptions, sources ...model.AttachmentSource) (UploadAttachmentResponse, error)
+
+func (a *api) ResumeUploadAttachment(ctx context.Context, state *UploadState, options UploadAttachmentOptions, sources ...model.AttachmentSource) (UploadAttachmentResponse, error) {
+	return a.e.ResumeUploadAttachment(ctx, state, options, sources...)
+}
+
+var resumeUploadAttachmentFactory = apiFactory[UploadAttachmentResponse, ResumeUploadAttachmentFn]()(
+	func(a *api, sc session.Context, u factoryUtils[UploadAttachmentResponse]) (ResumeUploadAttachmentFn, error) {
+		base := jsonx.FirstOr(sc.GetZpwService("file"), "")
+		serviceURL := u.MakeURL(base+"/api", nil, false)
+
+		return func(ctx context.Context, state *UploadState, options UploadAttachmentOptions, sources ...model.AttachmentSource) (UploadAttachmentResponse, error) {
+			if state == nil || len(state.Files) == 0 {
+				return nil, ErrUploadStateEmpty
+			}
+			if len(sources) != 0 && len(sources) != len(state.Files) {
+				return nil, ErrUploadSourceMismatch
+			}
+
+			var closers []io.Closer
+			defer func() {
+				for _, c := range closers {
+					_ = c.Close()
+				}
+			}()
+
+			srcs := make([]model.AttachmentSource, len(state.Files))
+			readers := make([]io.Reader, len(state.Files))
+
+			for i := range state.Files {
+				f := &state.Files[i]
+				slices.Sort(f.AckedChunks)
+				f.AckedChunks = slices.Compact(f.AckedChunks)
+
+				switch {
+				case len(sources) != 0:
+					srcs[i] = sources[i]
+				case f.Path != "":
+					srcs[i] = model.NewStringAttachment(f.Path)
+				case f.Done():
+					continue
+				default:
+					return nil, ErrUploadSourceMissing
+				}
+
+				if f.Done() {
+					continue
+				}
+
+				if path := srcs[i].String(); path != "" {
+					r, err := os.Open(path)
+					if err != nil {
+						return nil, errs.WrapZCA("failed to read file", "api.ResumeUploadAttachment", err)
+					}
+					closers = append(closers, r)
+
+					info, err := r.Stat()
+					if err != nil {
+						return nil, errs.WrapZCA("failed to read file", "api.ResumeUploadAttachment", err)
+					}
+					if info.Size() != f.TotalSize {
+						return nil, ErrUploadSourceMismatch
+					}
+					readers[i] = r
+				} else if obj := srcs[i].Object(); obj != nil {
+					if obj.Metadata.Size != f.TotalSize {
+						return nil, ErrUploadSourceMismatch
+					}
+					readers[i] = obj.Data
+				} else {
+					return nil, ErrUploadSourceMissing
+				}
+			}
+
+			return uploadAttachmentChunks(ctx, sc, u, serviceURL, state, srcs, readers, options)
+		}, nil
+	},
+)
@@ -132,13 +132,12 @@ var sendGIFFactory = apiFactory[*SendGIFResponse, SendGIFFn]()(
 				content.Thumb = thumbSource
 			}
 
-			forms, err := httpx.BuildFormData(
-				"chunkContent", bytes.NewReader(data),
+			chunks, err := httpx.NewChunkedForm(
+				"chunkContent", bytes.NewReader(data), int64(len(data)), config.GIFChunkSize,
 				httpx.WithContentType("application/octet-stream"),
 				httpx.WithFileName(fileName),
-				httpx.WithChunkSize(config.GIFChunkSize),
 			)
-			if err != nil || len(forms) == 0 || forms[0] == nil {
+			if err != nil {
 				return nil, errs.WrapZCA("failed to build form data", "api.SendGIF", err)
 			}
 
@@ -158,7 +157,7 @@ var sendGIFFactory = apiFactory[*SendGIFResponse, SendGIFFn]()(
 				TTL:        content.TTL,
 				Thumb:      thumb.URL,
 				Checksum:   content.Attachment.GetLargeFileMD5().Checksum,
-				TotalChunk: len(forms),
+				TotalChunk: chunks.TotalChunk(),
 				ChunkID:    1,
 			}
 
@@ -173,8 +172,12 @@ var sendGIFFactory = apiFactory[*SendGIFResponse, SendGIFFn]()(
 			var results atomic.Pointer[SendGIFResponse]
 
 			g, gctx := errgroup.WithContext(ctx)
-			for i := range forms {
+			for i := range chunks.TotalChunk() {
 				chunk := i
+				form, err := chunks.Chunk(chunk)
+				if err != nil {
+					return nil, errs.WrapZCA("failed to build form data", "api.SendGIF", err)
+				}
 
 				g.Go(func() error {
 					p := payload
@@ -192,9 +195,10 @@ var sendGIFFactory = apiFactory[*SendGIFResponse, SendGIFFn]()(
 
 					reqCtx := gctx
 					resp, err := u.Request(reqCtx, url, &httpx.RequestOptions{
-						Method:  http.MethodPost,
-						Headers: forms[chunk].Header,
-						Body:    forms[chunk].Body,
+						Method:        http.MethodPost,
+						Headers:       form.Header,
+						Body:          form.Body,
+						ContentLength: form.ContentLength,
 					})
 					if err != nil {
 						return err
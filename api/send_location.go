@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Amrakk/zcago/errs"
+	"github.com/Amrakk/zcago/internal/httpx"
+	"github.com/Amrakk/zcago/internal/jsonx"
+	"github.com/Amrakk/zcago/model"
+	"github.com/Amrakk/zcago/session"
+)
+
+var ErrInvalidLocation = errs.NewZCA("latitude must be within [-90, 90] and longitude within [-180, 180]", "api.SendLocation")
+
+// locationMsgType is the msgType of location messages, received as "chat.location.new".
+// It is also the qmsgType of a quoted location, see SendMessageQuote.GetMessageType.
+const locationMsgType = 43
+
+type (
+	SendLocationOptions struct {
+		Latitude  float64
+		Longitude float64
+		Title     string // Optional name of the place
+		Address   string // Optional address shown under the title
+		TTL       int    // Time to live in milliseconds
+	}
+	SendLocationResponse struct {
+		MsgID int `json:"msgId"`
+	}
+	SendLocationFn = func(ctx context.Context, threadID string, threadType model.ThreadType, options SendLocationOptions) (*SendLocationResponse, error)
+)
+
+func (a *api) SendLocation(ctx context.Context, threadID string, threadType model.ThreadType, options SendLocationOptions) (*SendLocationResponse, error) {
+	return a.e.SendLocation(ctx, threadID, threadType, options)
+}
+
+var sendLocationFactory = apiFactory[*SendLocationResponse, SendLocationFn]()(
+	func(a *api, sc session.Context, u factoryUtils[*SendLocationResponse]) (SendLocationFn, error) {
+		base := jsonx.FirstOr(sc.GetZpwService("file"), "")
+		serviceURLs := map[model.ThreadType]string{
+			model.ThreadTypeUser:  u.MakeURL(base+"/api/message/forward", nil, true),
+			model.ThreadTypeGroup: u.MakeURL(base+"/api/group/forward", nil, true),
+		}
+
+		return func(ctx context.Context, threadID string, threadType model.ThreadType, options SendLocationOptions) (*SendLocationResponse, error) {
+			if math.IsNaN(options.Latitude) || math.IsNaN(options.Longitude) ||
+				options.Latitude < -90 || options.Latitude > 90 || options.Longitude < -180 || options.Longitude > 180 {
+				return nil, ErrInvalidLocation
+			}
+
+			msgInfo := map[string]any{
+				"latitude":  options.Latitude,
+				"longitude": options.Longitude,
+				"title":     options.Title,
+				"address":   options.Address,
+			}
+
+			payload := map[string]any{
+				"clientId": strconv.FormatInt(time.Now().UnixMilli(), 10),
+				"ttl":      options.TTL,
+				"msgType":  locationMsgType,
+				"msgInfo":  jsonx.Stringify(msgInfo),
+				"imei":     sc.IMEI(),
+			}
+
+			if threadType == model.ThreadTypeUser {
+				payload["toId"] = threadID
+			} else {
+				payload["grid"] = threadID
+				payload["visibility"] = 0
+			}
+
+			enc, err := u.EncodeAES(jsonx.Stringify(payload))
+			if err != nil {
+				return nil, errs.WrapZCA("failed to encrypt params", "api.SendLocation", err)
+			}
+
+			body := httpx.BuildFormBody(map[string]string{"params": enc})
+			resp, err := u.Request(ctx, serviceURLs[threadType], &httpx.RequestOptions{
+				Method: http.MethodPost,
+				Body:   body,
+			})
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+
+			return u.Resolve(resp, true)
+		}, nil
+	},
+)
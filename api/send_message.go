@@ -24,28 +24,32 @@ var (
 	ErrInvalidMention             = errs.NewZCA("Invalid mentions: total mention characters exceed message length", "api.SendMessage")
 	ErrInvalidWebchatQuote        = errs.NewZCA("invalid quote: content must be string for msgType 'webchat'", "api.SendMessage")
 	ErrUnsupportedQuotedGroupPoll = errs.NewZCA("quoted message type 'group.poll' is not supported", "api.SendMessage")
+	ErrQuoteWithoutText           = errs.NewZCA("quote requires a text message, attachments cannot quote", "api.SendMessage")
 )
 
-type TextStyle string
+type (
+	TextStyle    = model.TextStyle
+	MessageStyle = model.MessageStyle
+)
 
 const (
-	TextStyleBold          TextStyle = "b"
-	TextStyleItalic        TextStyle = "i"
-	TextStyleUnderline     TextStyle = "u"
-	TextStyleStrikeThrough TextStyle = "s"
+	TextStyleBold          = model.TextStyleBold
+	TextStyleItalic        = model.TextStyleItalic
+	TextStyleUnderline     = model.TextStyleUnderline
+	TextStyleStrikeThrough = model.TextStyleStrikeThrough
 
-	TextStyleRed    TextStyle = "c_db342e"
-	TextStyleOrange TextStyle = "c_f27806"
-	TextStyleYellow TextStyle = "c_f7b503"
-	TextStyleGreen  TextStyle = "c_15a85f"
+	TextStyleRed    = model.TextStyleRed
+	TextStyleOrange = model.TextStyleOrange
+	TextStyleYellow = model.TextStyleYellow
+	TextStyleGreen  = model.TextStyleGreen
 
-	TextStyleSmall TextStyle = "f_13"
-	TextStyleBig   TextStyle = "f_18"
+	TextStyleSmall = model.TextStyleSmall
+	TextStyleBig   = model.TextStyleBig
 
-	TextStyleOrderedList   TextStyle = "lst_2"
-	TextStyleUnorderedList TextStyle = "lst_1"
+	TextStyleOrderedList   = model.TextStyleOrderedList
+	TextStyleUnorderedList = model.TextStyleUnorderedList
 
-	TextStyleIndent TextStyle = "ind_$"
+	TextStyleIndent = model.TextStyleIndent
 )
 
 type (
@@ -61,13 +65,6 @@ type (
 		TS  string `json:"ts"`
 		TTL int    `json:"ttl"`
 	}
-	MessageStyle struct {
-		Start int       `json:"start"`
-		Len   int       `json:"len"`
-		Style TextStyle `json:"st"`
-
-		IndentSize int `json:"indentSize"` // Used for indent style
-	}
 	MessageContent struct {
 		Msg     string
 		Style   []MessageStyle
@@ -77,6 +74,14 @@ type (
 		Mentions    []model.TMention
 		Attachments []model.AttachmentSource
 		TTL         int // Time to live in milliseconds
+
+		// ClientID identifies the message on the client side, generated when zero.
+		// Reusing the ID of a previous attempt lets Zalo recognize a resend of the same message.
+		ClientID int64
+
+		// UploadedAttachments are sent as-is without uploading again,
+		// e.g. results of a previous UploadAttachment call reused across threads.
+		UploadedAttachments []UploadAttachment
 	}
 
 	sendData struct {
@@ -193,7 +198,7 @@ var sendMessageFactory = apiFactory[*SendMessageResponse, SendMessageFn]()(
 
 			payload := map[string]any{
 				"message":  message.Msg,
-				"clientId": time.Now().UnixMilli(),
+				"clientId": clientIDOrNow(message.ClientID),
 				"ttl":      message.TTL,
 			}
 
@@ -241,9 +246,6 @@ var sendMessageFactory = apiFactory[*SendMessageResponse, SendMessageFn]()(
 			}, nil
 		}
 
-		// handleAttachment := func(threadID string, threadType model.ThreadType, message MessageContent) ([]sendData, error) {
-		// }
-
 		sendMessage := func(ctx context.Context, sendData []sendData) ([]SendMessageResult, error) {
 			var (
 				mu      sync.Mutex
@@ -253,6 +255,10 @@ var sendMessageFactory = apiFactory[*SendMessageResponse, SendMessageFn]()(
 
 			for _, data := range sendData {
 				g.Go(func() error {
+					if err := sc.SendLimiter().Wait(gctx); err != nil {
+						return err
+					}
+
 					resp, err := u.Request(gctx, data.URL, &httpx.RequestOptions{
 						Method:  http.MethodPost,
 						Body:    data.Body,
@@ -283,16 +289,28 @@ var sendMessageFactory = apiFactory[*SendMessageResponse, SendMessageFn]()(
 		}
 
 		return func(ctx context.Context, threadID string, threadType model.ThreadType, message MessageContent) (*SendMessageResponse, error) {
-			if len(message.Msg) == 0 && (len(message.Attachments) == 0) {
+			totalFile := len(message.Attachments) + len(message.UploadedAttachments)
+			if len(message.Msg) == 0 && totalFile == 0 {
 				return nil, ErrMessageContentEmpty
 			}
-			if isExceedMaxFile(len(message.Attachments)) {
+			// Only the text message carries the quote
+			if len(message.Msg) == 0 && message.Quote != nil {
+				return nil, ErrQuoteWithoutText
+			}
+			if isExceedMaxFile(totalFile) {
 				return nil, errs.ErrExceedMaxFile
 			}
 
 			results := &SendMessageResponse{}
 
-			if len(message.Msg) > 0 {
+			// A single image carries the text as its description,
+			// otherwise the text is sent as a separate message.
+			desc := ""
+			if len(message.Msg) > 0 && message.Quote == nil && isSingleImage(message) {
+				desc = message.Msg
+			}
+
+			if len(message.Msg) > 0 && desc == "" {
 				data, err := handleMessage(threadID, threadType, message)
 				if err != nil {
 					return nil, err
@@ -310,11 +328,57 @@ var sendMessageFactory = apiFactory[*SendMessageResponse, SendMessageFn]()(
 				}
 			}
 
+			if totalFile > 0 {
+				uploaded := append([]UploadAttachment(nil), message.UploadedAttachments...)
+				if len(message.Attachments) > 0 {
+					up, err := a.UploadAttachment(ctx, threadID, threadType, message.Attachments...)
+					if err != nil {
+						return nil, err
+					}
+					uploaded = append(uploaded, up...)
+				}
+
+				clientID := clientIDOrNow(message.ClientID)
+				if len(message.Msg) > 0 && desc == "" {
+					// The text message already used this client ID
+					clientID++
+				}
+
+				data, err := buildAttachmentSendData(u, serviceURLs.Attachment[threadType], threadID, threadType, desc, clientID, uploaded)
+				if err != nil {
+					return nil, err
+				}
+
+				resps, err := sendMessage(ctx, data)
+				if err != nil {
+					return nil, err
+				}
+				results.Attachment = resps
+			}
+
 			return results, nil
 		}, nil
 	},
 )
 
+// MsgID returns the ID of the text message, or of the first attachment when no text was sent.
+func (r *SendMessageResponse) MsgID() string {
+	if r.Message != nil {
+		return r.Message.MsgID
+	}
+	if len(r.Attachment) > 0 {
+		return r.Attachment[0].MsgID
+	}
+	return ""
+}
+
+func clientIDOrNow(id int64) int64 {
+	if id != 0 {
+		return id
+	}
+	return time.Now().UnixMilli()
+}
+
 func (q *SendMessageQuote) BuildAttachmentMessagePayload() any {
 	if q.Content.String != nil {
 		return q.PropertyExt
@@ -396,16 +460,16 @@ func (q *SendMessageQuote) GetMessageType() int {
 		return 37
 	case "chat.recommended":
 		return 38
-	case "chat.lin:":
+	case "chat.link":
 		return 38 // don't know || if (msgType === "chat.link") return 1;
-	case "chat.video.ms":
+	case "chat.video.msg":
 		return 44 // not sure
-	case "share.fil:":
+	case "share.file":
 		return 46
 	case "chat.gif":
 		return 49
-	case "chat.location.ne":
-		return 43
+	case "chat.location.new":
+		return locationMsgType
 	default:
 		return 1
 	}
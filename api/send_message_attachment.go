@@ -0,0 +1,133 @@
+package api
+
+import (
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Amrakk/zcago/config"
+	"github.com/Amrakk/zcago/errs"
+	"github.com/Amrakk/zcago/internal/httpx"
+	"github.com/Amrakk/zcago/internal/jsonx"
+	"github.com/Amrakk/zcago/model"
+)
+
+var ErrInvalidUploadedAttachment = errs.NewZCA("uploaded attachment has neither image nor file info", "api.SendMessage")
+
+// buildAttachmentSendData builds the requests sending uploaded attachments to a thread,
+// baseURL being the attachment service URL of the thread type. Several images are
+// grouped in a single layout, desc is the description of a single image.
+func buildAttachmentSendData(
+	u factoryUtils[*SendMessageResponse],
+	baseURL string,
+	threadID string,
+	threadType model.ThreadType,
+	desc string,
+	clientID int64,
+	uploaded []UploadAttachment,
+) ([]sendData, error) {
+	isGroup := threadType == model.ThreadTypeGroup
+	isMultiFile := len(uploaded) > 1
+	groupLayoutID := clientID
+	indexInGroupLayout := len(uploaded) - 1
+
+	data := make([]sendData, 0, len(uploaded))
+	for _, att := range uploaded {
+		var (
+			path    string
+			payload map[string]any
+		)
+
+		switch {
+		case att.Image != nil:
+			img := att.Image
+			path = "/photo_original/send"
+			payload = map[string]any{
+				"photoId":  img.PhotoID,
+				"clientId": strconv.FormatInt(clientID, 10),
+				"desc":     desc,
+				"width":    img.Width,
+				"height":   img.Height,
+				"rawUrl":   img.NormalURL,
+				"hdUrl":    img.HDURL,
+				"thumbUrl": img.ThumbURL,
+				"hdSize":   strconv.FormatInt(att.TotalSize, 10),
+				"zsource":  -1,
+				"ttl":      0,
+				"jcp":      `{"convertible":"jxl"}`,
+			}
+			if isGroup {
+				payload["oriUrl"] = img.NormalURL
+			} else {
+				payload["normalUrl"] = img.NormalURL
+			}
+			if isMultiFile {
+				payload["groupLayoutId"] = groupLayoutID
+				payload["isGroupLayout"] = 1
+				payload["idInGroup"] = indexInGroupLayout
+				payload["totalItemInGroup"] = len(uploaded)
+				indexInGroupLayout--
+			}
+
+		case att.File != nil:
+			f := att.File
+			path = "/asyncfile/msg"
+			payload = map[string]any{
+				"fileId":      f.FileID,
+				"checksum":    f.Checksum,
+				"checksumSha": "",
+				"extention":   strings.TrimPrefix(filepath.Ext(f.FileName), "."),
+				"totalSize":   att.TotalSize,
+				"fileName":    f.FileName,
+				"clientId":    att.ClientFileID,
+				"fType":       1,
+				"fileCount":   0,
+				"fdata":       "{}",
+				"fileUrl":     f.FileURL,
+				"zsource":     -1,
+				"ttl":         0,
+			}
+
+		default:
+			return nil, ErrInvalidUploadedAttachment
+		}
+
+		if isGroup {
+			payload["grid"] = threadID
+		} else {
+			payload["toid"] = threadID
+		}
+		clientID++
+
+		enc, err := u.EncodeAES(jsonx.Stringify(payload))
+		if err != nil {
+			return nil, errs.WrapZCA("failed to encrypt params", "api.SendMessage", err)
+		}
+
+		url, err := url.Parse(baseURL)
+		if err != nil {
+			return nil, errs.WrapZCA("failed to parse attachment URL", "api.SendMessage", err)
+		}
+
+		url.Path += path
+		data = append(data, sendData{
+			URL:  url.String(),
+			Body: httpx.BuildFormBody(map[string]string{"params": enc}),
+		})
+	}
+
+	return data, nil
+}
+
+// isSingleImage reports whether the message carries a single attachment which is an image.
+func isSingleImage(message MessageContent) bool {
+	if len(message.Attachments)+len(message.UploadedAttachments) != 1 {
+		return false
+	}
+	if len(message.UploadedAttachments) == 1 {
+		return message.UploadedAttachments[0].Image != nil
+	}
+	return slices.Contains(config.SupportedImageExtensions, message.Attachments[0].GetExtension())
+}
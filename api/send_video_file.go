@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+
+	"github.com/Amrakk/zcago/errs"
+	"github.com/Amrakk/zcago/model"
+	"github.com/Amrakk/zcago/session"
+)
+
+var (
+	ErrMissingVideoThumbnail = errs.NewZCA("thumbnail is required, set Thumbnail or use a Probe returning one", "api.SendVideoFile")
+	ErrMissingVideoMetadata  = errs.NewZCA("video duration, width and height could not be probed", "api.SendVideoFile")
+)
+
+type (
+	SendVideoFileOptions struct {
+		Msg       string                  // Optional message to send along with the video
+		Video     model.AttachmentSource  // Local video file
+		Thumbnail *model.AttachmentSource // Thumbnail image, required unless Probe returns one
+		Duration  int                     // Video duration in milliseconds, probed when zero
+		Width     int                     // Width of the video, probed when zero
+		Height    int                     // Height of the video, probed when zero
+		TTL       int                     // Time to live in milliseconds
+
+		// Probe fills in the metadata not set above, defaults to MP4VideoProbe.
+		Probe VideoProbe
+		// OnUploadProgress receives the progress of the video upload.
+		OnUploadProgress UploadProgressFn
+	}
+
+	// VideoProbe extracts metadata from a local video, e.g. by running ffprobe.
+	// A probe reading an object source must leave its reader usable for the upload.
+	VideoProbe interface {
+		ProbeVideo(ctx context.Context, source model.AttachmentSource) (VideoMetadata, error)
+	}
+	VideoMetadata struct {
+		Duration  int // Duration in milliseconds
+		Width     int
+		Height    int
+		Thumbnail *model.AttachmentSource // Frame to use as thumbnail, when the probe can extract one
+	}
+
+	SendVideoFileFn = func(ctx context.Context, threadID string, threadType model.ThreadType, options SendVideoFileOptions) (*SendVideoResponse, error)
+)
+
+// MP4VideoProbe reads the duration and dimensions from the moov box of mp4 and mov
+// files. Object sources are only probed when their reader implements io.ReaderAt.
+// It does not extract thumbnails, so SendVideoFile needs options.Thumbnail with it.
+// Files it can't read, e.g. corrupt or not mp4, return an error.
+type MP4VideoProbe struct{}
+
+func (MP4VideoProbe) ProbeVideo(_ context.Context, source model.AttachmentSource) (VideoMetadata, error) {
+	info, err := probeMP4(source)
+	if err != nil {
+		return VideoMetadata{}, errs.WrapZCA("failed to read mp4 headers", "api.MP4VideoProbe", err)
+	}
+
+	return VideoMetadata{
+		Duration: int(info.Duration.Milliseconds()),
+		Width:    info.Width,
+		Height:   info.Height,
+	}, nil
+}
+
+func (a *api) SendVideoFile(ctx context.Context, threadID string, threadType model.ThreadType, options SendVideoFileOptions) (*SendVideoResponse, error) {
+	return a.e.SendVideoFile(ctx, threadID, threadType, options)
+}
+
+var sendVideoFileFactory = apiFactory[*SendVideoResponse, SendVideoFileFn]()(
+	func(a *api, sc session.Context, u factoryUtils[*SendVideoResponse]) (SendVideoFileFn, error) {
+		return func(ctx context.Context, threadID string, threadType model.ThreadType, options SendVideoFileOptions) (*SendVideoResponse, error) {
+			if !options.Video.IsString() && !options.Video.IsObject() {
+				return nil, errs.ErrSourceEmpty
+			}
+
+			thumbnail := options.Thumbnail
+			missing := options.Duration == 0 || options.Width == 0 || options.Height == 0
+			// The default probe can't provide a thumbnail, only a custom one is worth running for it
+			if missing || (thumbnail == nil && options.Probe != nil) {
+				probe := options.Probe
+				if probe == nil {
+					probe = MP4VideoProbe{}
+				}
+
+				meta, err := probe.ProbeVideo(ctx, options.Video)
+				if err != nil {
+					return nil, errs.WrapZCA("failed to probe video", "api.SendVideoFile", err)
+				}
+
+				if options.Duration == 0 {
+					options.Duration = meta.Duration
+				}
+				if options.Width == 0 || options.Height == 0 {
+					options.Width, options.Height = meta.Width, meta.Height
+				}
+				if thumbnail == nil {
+					thumbnail = meta.Thumbnail
+				}
+			}
+			if options.Duration <= 0 || options.Width <= 0 || options.Height <= 0 {
+				return nil, ErrMissingVideoMetadata
+			}
+
+			if thumbnail == nil {
+				return nil, ErrMissingVideoThumbnail
+			}
+			thumb, err := a.UploadThumbnail(ctx, *thumbnail)
+			if err != nil {
+				return nil, errs.WrapZCA("failed to upload thumbnail", "api.SendVideoFile", err)
+			}
+
+			uploaded, err := a.UploadAttachmentWithOptions(ctx, threadID, threadType, UploadAttachmentOptions{OnProgress: options.OnUploadProgress}, options.Video)
+			if err != nil {
+				return nil, err
+			}
+			if len(uploaded) == 0 || uploaded[0].File == nil {
+				return nil, errs.ErrFileContentUnavailable
+			}
+
+			return a.SendVideo(ctx, threadID, threadType, SendVideoOptions{
+				Msg:          options.Msg,
+				VideoURL:     uploaded[0].File.FileURL,
+				ThumbnailURL: thumb.URL,
+				Duration:     options.Duration,
+				Width:        options.Width,
+				Height:       options.Height,
+				TTL:          options.TTL,
+			})
+		}, nil
+	},
+)
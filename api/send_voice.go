@@ -2,21 +2,40 @@ package api
 
 import (
 	"context"
+	"io"
 	"net/http"
+	"os"
+	"slices"
 	"strconv"
 	"time"
 
 	"github.com/Amrakk/zcago/errs"
 	"github.com/Amrakk/zcago/internal/httpx"
 	"github.com/Amrakk/zcago/internal/jsonx"
+	"github.com/Amrakk/zcago/internal/mp4x"
 	"github.com/Amrakk/zcago/model"
 	"github.com/Amrakk/zcago/session"
 )
 
+var (
+	ErrVoiceSourceEmpty      = errs.NewZCA("voice URL or voice source must be provided", "api.SendVoice")
+	ErrInvalidVoiceExtension = errs.NewZCA("voice must be an m4a, aac or amr file", "api.SendVoice")
+)
+
+var supportedVoiceExtensions = []string{"m4a", "aac", "amr"}
+
 type (
 	SendVoiceOptions struct {
 		VoiceURL string // URL of the voice
 		TTL      int    // Time to live in milliseconds
+
+		// Voice is a local m4a, aac or amr file uploaded when VoiceURL is empty.
+		Voice *model.AttachmentSource
+		// Duration of the voice, read from the file when zero and the source is an m4a
+		// file or a reader implementing io.ReaderAt.
+		Duration time.Duration
+		// OnUploadProgress receives the progress of the Voice upload.
+		OnUploadProgress UploadProgressFn
 	}
 	SendVoiceResponse struct {
 		MsgID int `json:"msgId"`
@@ -36,26 +55,68 @@ var sendVoiceFactory = apiFactory[*SendVoiceResponse, SendVoiceFn]()(
 			model.ThreadTypeGroup: u.MakeURL(base+"/api/group/forward", nil, true),
 		}
 
-		return func(ctx context.Context, threadID string, threadType model.ThreadType, options SendVoiceOptions) (*SendVoiceResponse, error) {
-			head, err := u.Request(ctx, options.VoiceURL, &httpx.RequestOptions{
-				Method: http.MethodHead,
-				Raw:    true,
-			})
+		uploadVoice := func(ctx context.Context, threadID string, threadType model.ThreadType, source model.AttachmentSource, onProgress UploadProgressFn) (string, int64, error) {
+			if !slices.Contains(supportedVoiceExtensions, source.GetExtension()) {
+				return "", 0, ErrInvalidVoiceExtension
+			}
+
+			uploaded, err := a.UploadAttachmentWithOptions(ctx, threadID, threadType, UploadAttachmentOptions{OnProgress: onProgress}, source)
 			if err != nil {
-				return nil, errs.ErrFileContentUnavailable
+				return "", 0, err
 			}
-			defer head.Body.Close()
+			if len(uploaded) == 0 || uploaded[0].File == nil {
+				return "", 0, errs.ErrFileContentUnavailable
+			}
+
+			return uploaded[0].File.FileURL, uploaded[0].TotalSize, nil
+		}
+
+		return func(ctx context.Context, threadID string, threadType model.ThreadType, options SendVoiceOptions) (*SendVoiceResponse, error) {
+			var (
+				voiceURL = options.VoiceURL
+				fileSize int64
+				duration = options.Duration
+			)
+
+			switch {
+			case voiceURL != "":
+				head, err := u.Request(ctx, voiceURL, &httpx.RequestOptions{
+					Method: http.MethodHead,
+					Raw:    true,
+				})
+				if err != nil {
+					return nil, errs.ErrFileContentUnavailable
+				}
+				defer head.Body.Close()
+
+				fileSize = head.ContentLength
+				if fileSize == -1 {
+					fileSize = 0
+				}
+
+			case options.Voice != nil:
+				if duration == 0 {
+					duration = voiceDuration(*options.Voice)
+				}
 
-			fileSize := head.ContentLength
-			if fileSize == -1 {
-				fileSize = 0
+				var err error
+				voiceURL, fileSize, err = uploadVoice(ctx, threadID, threadType, *options.Voice, options.OnUploadProgress)
+				if err != nil {
+					return nil, err
+				}
+
+			default:
+				return nil, ErrVoiceSourceEmpty
 			}
 
 			msgInfo := map[string]any{
-				"voiceUrl": options.VoiceURL,
-				"m4aUrl":   options.VoiceURL,
+				"voiceUrl": voiceURL,
+				"m4aUrl":   voiceURL,
 				"fileSize": fileSize,
 			}
+			if duration > 0 {
+				msgInfo["duration"] = duration.Milliseconds()
+			}
 
 			payload := map[string]any{
 				"clientId": strconv.FormatInt(time.Now().UnixMilli(), 10),
@@ -92,3 +153,42 @@ var sendVoiceFactory = apiFactory[*SendVoiceResponse, SendVoiceFn]()(
 		}, nil
 	},
 )
+
+// voiceDuration reads the duration of an m4a voice, 0 when it cannot be read.
+func voiceDuration(source model.AttachmentSource) time.Duration {
+	if source.GetExtension() != "m4a" {
+		return 0
+	}
+
+	info, err := probeMP4(source)
+	if err != nil {
+		return 0
+	}
+	return info.Duration
+}
+
+// probeMP4 reads the moov box of a local mp4, m4a or mov source. Object sources
+// are only probed when their reader implements io.ReaderAt, so the data is left
+// untouched for the upload.
+func probeMP4(source model.AttachmentSource) (mp4x.Info, error) {
+	if path := source.String(); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return mp4x.Info{}, err
+		}
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil {
+			return mp4x.Info{}, err
+		}
+		return mp4x.Probe(f, info.Size())
+	}
+
+	if obj := source.Object(); obj != nil {
+		if ra, ok := obj.Data.(io.ReaderAt); ok {
+			return mp4x.Probe(ra, obj.Metadata.Size)
+		}
+	}
+	return mp4x.Info{}, errs.ErrSourceEmpty
+}
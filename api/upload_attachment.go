@@ -12,6 +12,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Amrakk/zcago/config"
 	"github.com/Amrakk/zcago/errs"
 	"github.com/Amrakk/zcago/internal/httpx"
 	"github.com/Amrakk/zcago/internal/jsonx"
@@ -21,13 +22,6 @@ import (
 )
 
 type (
-	fileData struct {
-		FileName  string `json:"fileName"`
-		TotalSize int64  `json:"totalSize"`
-
-		Width  *int `json:"width,omitempty"`
-		Height *int `json:"height,omitempty"`
-	}
 	attachmentParams struct {
 		ToID       *string `json:"toid,omitempty"`
 		Grid       *string `json:"grid,omitempty"`
@@ -40,15 +34,6 @@ type (
 		JXL        int     `json:"jxl"`
 		ChunkID    int     `json:"chunkId"`
 	}
-	fileAttachmentData struct {
-		FilePath     string                 `json:"filePath"`
-		FileType     model.FileType         `json:"fileType"` // "video" | "others"
-		ChunkContent []httpx.FormData       `json:"chunkContent"`
-		FileData     fileData               `json:"fileData"`
-		Params       attachmentParams       `json:"params"`
-		Source       model.AttachmentSource `json:"source"`
-	}
-
 	rawResponse struct {
 		Finished     bool `json:"finished"`
 		ClientFileID int  `json:"clientFileId"`
@@ -88,12 +73,137 @@ type (
 		Checksum string `json:"checksum"`
 	}
 
+	// UploadState tracks the progress of an UploadAttachment call so a failed
+	// upload can be continued with ResumeUploadAttachment. It is JSON serializable.
+	UploadState struct {
+		ThreadID   string            `json:"threadId"`
+		ThreadType model.ThreadType  `json:"threadType"`
+		Files      []UploadFileState `json:"files"`
+
+		late *lateCallbacks // Callbacks fired after the attempt that registered them failed
+	}
+	UploadFileState struct {
+		Index      int            `json:"index"`          // Index of the file in the uploaded sources
+		Path       string         `json:"path,omitempty"` // Set for path sources, used to reopen the file on resume
+		FileName   string         `json:"fileName"`
+		FileType   model.FileType `json:"fileType"`
+		ClientID   int64          `json:"clientId"`
+		ChunkSize  int64          `json:"chunkSize"`
+		TotalChunk int            `json:"totalChunk"`
+		TotalSize  int64          `json:"totalSize"`
+		Width      int            `json:"width"`
+		Height     int            `json:"height"`
+
+		AckedChunks  []int  `json:"ackedChunks"`      // 1-based IDs of the chunks acknowledged by Zalo
+		ClientFileID int    `json:"clientFileId"`     // From the last acknowledged chunk
+		FileID       string `json:"fileId,omitempty"` // Set for videos and other files once every chunk is acknowledged
+
+		Image *UploadImageInfo `json:"image,omitempty"` // Set once an image is uploaded
+		File  *UploadFileInfo  `json:"file,omitempty"`  // Set once the upload callback of a video or other file fired
+	}
+
+	// UploadIncompleteError is returned by UploadAttachment and ResumeUploadAttachment
+	// when some chunks were not sent. State holds what was acknowledged so far.
+	//
+	// Upload callbacks still pending when the error is returned stay registered
+	// but leave State untouched. The file URL of one firing later is kept aside
+	// so that ResumeUploadAttachment, given the same State, finishes the file
+	// without waiting for it again.
+	UploadIncompleteError struct {
+		State *UploadState
+		Err   error
+	}
+
 	UploadAttachmentResponse = []UploadAttachment
-	UploadAttachmentFn       = func(ctx context.Context, threadID string, threadType model.ThreadType, sources ...model.AttachmentSource) (UploadAttachmentResponse, error)
+	UploadAttachmentFn       = func(ctx context.Context, threadID string, threadType model.ThreadType, options UploadAttachmentOptions, sources ...model.AttachmentSource) (UploadAttachmentResponse, error)
 )
 
+// lateCallbacks keeps the file URLs delivered by upload callbacks of an abandoned attempt.
+type lateCallbacks struct {
+	mu   sync.Mutex
+	urls map[string]string // By file ID
+}
+
+func (l *lateCallbacks) put(fileID, url string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.urls == nil {
+		l.urls = make(map[string]string)
+	}
+	l.urls[fileID] = url
+}
+
+func (l *lateCallbacks) take(fileID string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	url, ok := l.urls[fileID]
+	delete(l.urls, fileID)
+	return url, ok
+}
+
+func (e *UploadIncompleteError) Error() string {
+	return "upload incomplete: " + e.Err.Error()
+}
+
+func (e *UploadIncompleteError) Unwrap() error { return e.Err }
+
+// Done reports whether every file of the upload finished.
+func (s *UploadState) Done() bool {
+	for i := range s.Files {
+		if !s.Files[i].Done() {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *UploadState) results() UploadAttachmentResponse {
+	results := make(UploadAttachmentResponse, 0, len(s.Files))
+	for _, f := range s.Files {
+		if !f.Done() {
+			continue
+		}
+		results = append(results, UploadAttachment{
+			Finished:     true,
+			ClientFileID: f.ClientFileID,
+			ChunkID:      f.TotalChunk,
+			FileType:     f.FileType,
+			TotalSize:    f.TotalSize,
+			Image:        f.Image,
+			File:         f.File,
+		})
+	}
+	return results
+}
+
+// Done reports whether the file is fully uploaded and its result is known.
+func (f *UploadFileState) Done() bool {
+	return f.Image != nil || f.File != nil
+}
+
+// MissingChunks returns the 1-based IDs of the chunks not acknowledged yet, in order.
+func (f *UploadFileState) MissingChunks() []int {
+	missing := make([]int, 0, max(f.TotalChunk-len(f.AckedChunks), 0))
+	for id := 1; id <= f.TotalChunk; id++ {
+		if !slices.Contains(f.AckedChunks, id) {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+func (f *UploadFileState) ack(chunkID int) {
+	if i, found := slices.BinarySearch(f.AckedChunks, chunkID); !found {
+		f.AckedChunks = slices.Insert(f.AckedChunks, i, chunkID)
+	}
+}
+
 func (a *api) UploadAttachment(ctx context.Context, threadID string, threadType model.ThreadType, sources ...model.AttachmentSource) (UploadAttachmentResponse, error) {
-	return a.e.UploadAttachment(ctx, threadID, threadType, sources...)
+	return a.e.UploadAttachment(ctx, threadID, threadType, UploadAttachmentOptions{}, sources...)
+}
+
+func (a *api) UploadAttachmentWithOptions(ctx context.Context, threadID string, threadType model.ThreadType, options UploadAttachmentOptions, sources ...model.AttachmentSource) (UploadAttachmentResponse, error) {
+	return a.e.UploadAttachment(ctx, threadID, threadType, options, sources...)
 }
 
 var uploadAttachmentFactory = apiFactory[UploadAttachmentResponse, UploadAttachmentFn]()(
@@ -102,12 +212,6 @@ var uploadAttachmentFactory = apiFactory[UploadAttachmentResponse, UploadAttachm
 		serviceURL := u.MakeURL(base+"/api", nil, false)
 		shareFile := sc.Settings().Features.ShareFile
 
-		pathMap := map[model.FileType]string{
-			model.FileTypeImage: "/photo_original/upload",
-			model.FileTypeVideo: "/asyncfile/upload",
-			model.FileTypeOther: "/asyncfile/upload",
-		}
-
 		isExceedMaxFile := func(totalFile int) bool {
 			return totalFile > shareFile.MaxFile
 		}
@@ -120,7 +224,7 @@ var uploadAttachmentFactory = apiFactory[UploadAttachmentResponse, UploadAttachm
 			return slices.Index(shareFile.RestrictedExtFile, ext) == -1
 		}
 
-		return func(ctx context.Context, threadID string, threadType model.ThreadType, sources ...model.AttachmentSource) (UploadAttachmentResponse, error) {
+		return func(ctx context.Context, threadID string, threadType model.ThreadType, options UploadAttachmentOptions, sources ...model.AttachmentSource) (UploadAttachmentResponse, error) {
 			if len(sources) == 0 {
 				return nil, errs.ErrSourceEmpty
 			}
@@ -130,21 +234,29 @@ var uploadAttachmentFactory = apiFactory[UploadAttachmentResponse, UploadAttachm
 
 			clientID := time.Now().UnixMilli()
 			chunkSize := shareFile.ChunkSizeFile
-			isGroup := threadType == model.ThreadTypeGroup
 
-			attachments := make([]fileAttachmentData, 0, len(sources))
-			for _, source := range sources {
+			// Files are streamed chunk by chunk, so they stay open until every chunk is sent
+			var closers []io.Closer
+			defer func() {
+				for _, c := range closers {
+					_ = c.Close()
+				}
+			}()
+
+			state := &UploadState{
+				ThreadID:   threadID,
+				ThreadType: threadType,
+				Files:      make([]UploadFileState, 0, len(sources)),
+			}
+			readers := make([]io.Reader, 0, len(sources))
+
+			for index, source := range sources {
 				var (
 					reader       io.Reader
-					closer       io.Closer
+					filePath     string
 					fileName     string
 					fileMetadata model.AttachmentMetadata
 				)
-				cleanup := func() {
-					if closer != nil {
-						_ = closer.Close()
-					}
-				}
 
 				if f := source.String(); f != "" {
 					r, err := os.Open(f)
@@ -153,64 +265,25 @@ var uploadAttachmentFactory = apiFactory[UploadAttachmentResponse, UploadAttachm
 					}
 
 					reader = r
-					closer = r
+					closers = append(closers, r)
 
 					fileMetadata, fileName, err = sc.GetImageMetadata(f)
 					if err != nil {
-						cleanup()
 						return nil, err
 					}
+					filePath = f
 				} else if f := source.Object(); f != nil {
 					reader, fileName, fileMetadata = f.Data, f.Filename, f.Metadata
 				}
 
 				ext := source.GetExtension()
 				if !isValidExtension(ext) {
-					cleanup()
 					return nil, errs.ErrInvalidExtension
 				}
 				if isExceedMaxFileSize(fileMetadata.Size) {
-					cleanup()
 					return nil, errs.ErrExceedMaxFileSize
 				}
 
-				forms, err := httpx.BuildFormData(
-					"chunkContent", reader,
-					httpx.WithContentType("application/octet-stream"),
-					httpx.WithFileName(fileName),
-					httpx.WithChunkSize(chunkSize),
-				)
-				cleanup()
-
-				if err != nil || len(forms) == 0 {
-					return nil, errs.WrapZCA("failed to build form data", "api.UploadAttachment", err)
-				}
-
-				chunkContent := make([]httpx.FormData, len(forms))
-				for i, formData := range forms {
-					if formData != nil {
-						chunkContent[i] = *formData
-					}
-				}
-
-				params := attachmentParams{
-					FileName:   fileName,
-					ClientID:   clientID,
-					TotalSize:  fileMetadata.Size,
-					IMEI:       sc.IMEI(),
-					IsE2EE:     0,
-					JXL:        0,
-					ChunkID:    1,
-					TotalChunk: int((fileMetadata.Size + chunkSize - 1) / chunkSize),
-				}
-				clientID++
-
-				if isGroup {
-					params.Grid = &threadID
-				} else {
-					params.ToID = &threadID
-				}
-
 				var fileType model.FileType
 				switch ext {
 				case "jpg", "jpeg", "png", "webp":
@@ -221,148 +294,288 @@ var uploadAttachmentFactory = apiFactory[UploadAttachmentResponse, UploadAttachm
 					fileType = model.FileTypeOther
 				}
 
-				attachments = append(attachments, fileAttachmentData{
-					FilePath:     fileName,
-					FileType:     fileType,
-					ChunkContent: chunkContent,
-					FileData: fileData{
-						FileName:  fileName,
-						TotalSize: fileMetadata.Size,
-						Width:     &fileMetadata.Width,
-						Height:    &fileMetadata.Height,
-					},
-					Params: params,
-					Source: source,
+				state.Files = append(state.Files, UploadFileState{
+					Index:      index,
+					Path:       filePath,
+					FileName:   fileName,
+					FileType:   fileType,
+					ClientID:   clientID,
+					ChunkSize:  chunkSize,
+					TotalChunk: int((fileMetadata.Size + chunkSize - 1) / chunkSize),
+					TotalSize:  fileMetadata.Size,
+					Width:      fileMetadata.Width,
+					Height:     fileMetadata.Height,
 				})
+				readers = append(readers, reader)
+				clientID++
 			}
 
-			typeParam := "2"
-			if isGroup {
-				typeParam = "11"
-			}
+			return uploadAttachmentChunks(ctx, sc, u, serviceURL, state, sources, readers, options)
+		}, nil
+	},
+)
+
+// uploadAttachmentChunks sends the chunks of state not acknowledged yet and records
+// every acknowledgement in state. sources and readers are indexed like state.Files,
+// readers of finished files may be nil.
+func uploadAttachmentChunks(
+	ctx context.Context,
+	sc session.Context,
+	u factoryUtils[UploadAttachmentResponse],
+	serviceURL string,
+	state *UploadState,
+	sources []model.AttachmentSource,
+	readers []io.Reader,
+	options UploadAttachmentOptions,
+) (UploadAttachmentResponse, error) {
+	pathMap := map[model.FileType]string{
+		model.FileTypeImage: "/photo_original/upload",
+		model.FileTypeVideo: "/asyncfile/upload",
+		model.FileTypeOther: "/asyncfile/upload",
+	}
+
+	isGroup := state.ThreadType == model.ThreadTypeGroup
+
+	typeParam := "2"
+	if isGroup {
+		typeParam = "11"
+	}
 
-			if isGroup {
-				serviceURL += "/group"
-			} else {
-				serviceURL += "/message"
+	uploadURL := serviceURL + "/message"
+	if isGroup {
+		uploadURL = serviceURL + "/group"
+	}
+
+	if state.late == nil {
+		state.late = &lateCallbacks{}
+	}
+	late := state.late
+
+	var (
+		mu        sync.Mutex // Guards state, abandoned and callbacks
+		abandoned bool       // Set once the upload failed, later callbacks only go to late
+		callbacks int        // Number of registered upload callbacks
+		fired     = make(chan struct{}, len(state.Files))
+		g, gctx   = errgroup.WithContext(ctx)
+		progress  = newProgressReporter(options.OnProgress, len(state.Files))
+	)
+	abandon := func(err error) error {
+		mu.Lock()
+		abandoned = true
+		mu.Unlock()
+		return &UploadIncompleteError{State: state, Err: err}
+	}
+	g.SetLimit(config.MaxChunksInFlight)
+
+	for fi := range state.Files {
+		f := &state.Files[fi]
+		source := sources[fi]
+
+		newProgress := func() UploadProgress {
+			return UploadProgress{
+				FileIndex:  f.Index,
+				FileName:   f.FileName,
+				FileType:   f.FileType,
+				TotalChunk: f.TotalChunk,
+				TotalSize:  f.TotalSize,
 			}
+		}
+
+		// The file info of videos and other files is completed by the upload callback
+		awaitCallback := func(fileID string) {
+			uploadCallback := func(wsData model.UploadAttachment) {
+				checksum := source.GetLargeFileMD5()
 
-			var (
-				mu      sync.Mutex
-				g, gctx = errgroup.WithContext(ctx)
-				results = make([]UploadAttachment, 0, len(attachments))
-				cbWG    sync.WaitGroup
-			)
-
-			for ai := range attachments {
-				a := attachments[ai]
-				baseParams := a.Params
-
-				for ci := 0; ci < baseParams.TotalChunk; ci++ {
-					chunk := ci
-
-					g.Go(func() error {
-						p := baseParams
-						p.ChunkID = baseParams.ChunkID + chunk
-
-						enc, err := u.EncodeAES(jsonx.Stringify(p))
-						if err != nil {
-							return errs.WrapZCA("failed to encrypt params", "api.UploadAttachment", err)
-						}
-
-						url := u.MakeURL(
-							serviceURL+pathMap[a.FileType],
-							map[string]any{"type": typeParam, "params": enc},
-							true,
-						)
-						reqCtx := gctx
-						resp, err := u.Request(reqCtx, url, &httpx.RequestOptions{
-							Method:  http.MethodPost,
-							Headers: a.ChunkContent[chunk].Header,
-							Body:    a.ChunkContent[chunk].Body,
-						})
-						if err != nil {
-							return err
-						}
-
-						data, err := resolveResponse[rawResponse](sc, resp, true)
-						_ = resp.Body.Close()
-						if err != nil {
-							return err
-						}
-
-						hasFileID := data.FileID != nil && *data.FileID != "-1"
-						hasPhotoID := data.PhotoID != nil && *data.PhotoID != "-1"
-
-						if hasFileID || hasPhotoID {
-							switch a.FileType {
-							case model.FileTypeVideo, model.FileTypeOther:
-								fileID := *data.FileID
-
-								cbWG.Add(1)
-								uploadCallback := func(wsData model.UploadAttachment) {
-									defer cbWG.Done()
-									checksum := a.Source.GetLargeFileMD5()
-
-									result := UploadAttachment{
-										FileType:     a.FileType,
-										Finished:     data.Finished,
-										ClientFileID: data.ClientFileID,
-										ChunkID:      data.ChunkID,
-										TotalSize:    a.FileData.TotalSize,
-										File: &UploadFileInfo{
-											FileID:   fileID,
-											FileURL:  wsData.FileURL,
-											FileName: a.FileData.FileName,
-											Checksum: checksum.Checksum,
-										},
-									}
-
-									mu.Lock()
-									results = append(results, result)
-									mu.Unlock()
-								}
-
-								sc.UploadCallback().Set(fileID, uploadCallback, 0)
-
-							case model.FileTypeImage:
-								result := UploadAttachment{
-									FileType:     model.FileTypeImage,
-									Finished:     data.Finished,
-									ClientFileID: data.ClientFileID,
-									ChunkID:      data.ChunkID,
-									TotalSize:    a.FileData.TotalSize,
-
-									Image: &UploadImageInfo{
-										HDSize:    a.FileData.TotalSize,
-										PhotoID:   *data.PhotoID,
-										Width:     *a.FileData.Width,
-										Height:    *a.FileData.Height,
-										NormalURL: *data.NormalURL,
-										HDURL:     *data.HDURL,
-										ThumbURL:  *data.ThumbURL,
-									},
-								}
-
-								mu.Lock()
-								results = append(results, result)
-								mu.Unlock()
-							}
-						}
-						return nil
-					})
+				mu.Lock()
+				if abandoned {
+					mu.Unlock()
+					late.put(fileID, wsData.FileURL)
+					return
 				}
+				f.File = &UploadFileInfo{
+					FileID:   fileID,
+					FileURL:  wsData.FileURL,
+					FileName: f.FileName,
+					Checksum: checksum.Checksum,
+				}
+				mu.Unlock()
+
+				dp := newProgress()
+				dp.FileURL = wsData.FileURL
+				progress.finish(fi, dp)
+				fired <- struct{}{}
 			}
 
-			if err := g.Wait(); err != nil {
-				return nil, err
+			mu.Lock()
+			callbacks++
+			mu.Unlock()
+			sc.UploadCallback().Set(fileID, uploadCallback, 0)
+
+			// The callback of a previous attempt may have fired already. It was
+			// replaced above, so the URL it kept is the only delivery left.
+			if url, ok := late.take(fileID); ok {
+				sc.UploadCallback().Delete(fileID)
+				uploadCallback(model.NewUploadAttachment(fileID, url))
 			}
-			cbWG.Wait()
+		}
 
-			return results, nil
-		}, nil
-	},
-)
+		if f.Done() {
+			continue
+		}
+		if f.FileID != "" {
+			// Every chunk went through in a previous attempt, only the callback is missing
+			awaitCallback(f.FileID)
+			continue
+		}
+
+		chunks, err := httpx.NewChunkedForm(
+			"chunkContent", readers[fi], f.TotalSize, f.ChunkSize,
+			httpx.WithContentType("application/octet-stream"),
+			httpx.WithFileName(f.FileName),
+		)
+		if err != nil {
+			g.Go(func() error {
+				return errs.WrapZCA("failed to build form data", "api.UploadAttachment", err)
+			})
+			break
+		}
+
+		baseParams := attachmentParams{
+			FileName:   f.FileName,
+			ClientID:   f.ClientID,
+			TotalSize:  f.TotalSize,
+			IMEI:       sc.IMEI(),
+			IsE2EE:     0,
+			JXL:        0,
+			TotalChunk: f.TotalChunk,
+		}
+		if isGroup {
+			baseParams.Grid = &state.ThreadID
+		} else {
+			baseParams.ToID = &state.ThreadID
+		}
+
+		for _, id := range f.AckedChunks {
+			progress.acked(fi, chunks.ChunkLen(id-1))
+		}
+
+		stop := false
+		for _, chunkID := range f.MissingChunks() {
+			if gctx.Err() != nil {
+				stop = true
+				break
+			}
+
+			// Read before g.Go so sequential sources are consumed in order
+			// and at most MaxChunksInFlight+1 chunks are held at once
+			form, err := chunks.Chunk(chunkID - 1)
+			if err != nil {
+				g.Go(func() error {
+					return errs.WrapZCA("failed to read chunk", "api.UploadAttachment", err)
+				})
+				stop = true
+				break
+			}
+
+			g.Go(func() error {
+				p := baseParams
+				p.ChunkID = chunkID
+
+				enc, err := u.EncodeAES(jsonx.Stringify(p))
+				if err != nil {
+					return errs.WrapZCA("failed to encrypt params", "api.UploadAttachment", err)
+				}
+
+				url := u.MakeURL(
+					uploadURL+pathMap[f.FileType],
+					map[string]any{"type": typeParam, "params": enc},
+					true,
+				)
+				reqCtx := gctx
+				resp, err := u.Request(reqCtx, url, &httpx.RequestOptions{
+					Method:        http.MethodPost,
+					Headers:       form.Header,
+					Body:          form.Body,
+					ContentLength: form.ContentLength,
+				})
+				if err != nil {
+					return err
+				}
+
+				data, err := resolveResponse[rawResponse](sc, resp, true)
+				_ = resp.Body.Close()
+				if err != nil {
+					return err
+				}
+
+				acked := chunkID
+				if data.ChunkID > 0 {
+					acked = data.ChunkID
+				}
+
+				hasFileID := data.FileID != nil && *data.FileID != "-1"
+				hasPhotoID := data.PhotoID != nil && *data.PhotoID != "-1"
+
+				mu.Lock()
+				f.ack(acked)
+				f.ClientFileID = data.ClientFileID
+				if hasFileID && f.FileType != model.FileTypeImage {
+					f.FileID = *data.FileID
+				}
+				if hasPhotoID && f.FileType == model.FileTypeImage {
+					f.Image = &UploadImageInfo{
+						HDSize:    f.TotalSize,
+						PhotoID:   *data.PhotoID,
+						Width:     f.Width,
+						Height:    f.Height,
+						NormalURL: *data.NormalURL,
+						HDURL:     *data.HDURL,
+						ThumbURL:  *data.ThumbURL,
+					}
+				}
+				mu.Unlock()
+
+				cp := newProgress()
+				cp.ChunkID = chunkID
+				progress.chunk(fi, cp, chunks.ChunkLen(chunkID-1))
+
+				if hasFileID || hasPhotoID {
+					switch f.FileType {
+					case model.FileTypeVideo, model.FileTypeOther:
+						awaitCallback(*data.FileID)
+
+					case model.FileTypeImage:
+						dp := newProgress()
+						dp.FileURL = *data.HDURL
+						progress.finish(fi, dp)
+					}
+				}
+				return nil
+			})
+		}
+		if stop {
+			break
+		}
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, abandon(err)
+	}
+
+	// Every chunk is acknowledged, so no callback is registered anymore
+	mu.Lock()
+	pending := callbacks
+	mu.Unlock()
+	for range pending {
+		select {
+		case <-fired:
+		case <-ctx.Done():
+			return nil, abandon(ctx.Err())
+		}
+	}
+
+	return state.results(), nil
+}
 
 func (r *rawResponse) UnmarshalJSON(data []byte) error {
 	type alias rawResponse
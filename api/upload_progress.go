@@ -0,0 +1,75 @@
+package api
+
+import (
+	"sync"
+
+	"github.com/Amrakk/zcago/model"
+)
+
+type (
+	UploadProgress struct {
+		FileIndex  int            // Index of the file in the uploaded sources
+		FileName   string         // Name of the file
+		FileType   model.FileType // "image" | "video" | "others"
+		ChunkID    int            // 1-based ID of the chunk just acknowledged, 0 on the final event
+		TotalChunk int            // Number of chunks of the file
+		BytesSent  int64          // Bytes of the file acknowledged so far
+		TotalSize  int64          // Size of the file
+
+		// Done marks the final event of a file. For videos and other files it is
+		// sent when the upload callback fires, after Zalo finished processing the file.
+		Done    bool
+		FileURL string // URL of the uploaded file, set on the final event
+	}
+	UploadProgressFn = func(progress UploadProgress)
+
+	UploadAttachmentOptions struct {
+		// OnProgress receives the progress of every acknowledged chunk and a final event
+		// per file. Calls are serialized, and BytesSent only grows for a given file.
+		OnProgress UploadProgressFn
+	}
+)
+
+// progressReporter computes and reports the progress of the files of one upload.
+// Chunks are acknowledged in parallel, so every report goes through mu to keep
+// BytesSent growing and the final event last for each file.
+type progressReporter struct {
+	mu   sync.Mutex
+	fn   UploadProgressFn
+	sent []int64 // Bytes acknowledged per file
+	done []bool  // Whether the final event of the file was sent
+}
+
+func newProgressReporter(fn UploadProgressFn, files int) *progressReporter {
+	return &progressReporter{fn: fn, sent: make([]int64, files), done: make([]bool, files)}
+}
+
+// acked counts n bytes already acknowledged by a previous call, without reporting them.
+func (r *progressReporter) acked(fi int, n int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent[fi] += n
+}
+
+func (r *progressReporter) chunk(fi int, p UploadProgress, n int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent[fi] += n
+	if r.fn == nil || r.done[fi] {
+		return
+	}
+	p.BytesSent = r.sent[fi]
+	r.fn(p)
+}
+
+func (r *progressReporter) finish(fi int, p UploadProgress) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fn == nil || r.done[fi] {
+		return
+	}
+	r.done[fi] = true
+	p.BytesSent = p.TotalSize
+	p.Done = true
+	r.fn(p)
+}
@@ -2,6 +2,7 @@ package zcago
 
 import (
 	"context"
+	"io"
 
 	"github.com/Amrakk/zcago/api"
 	"github.com/Amrakk/zcago/listener"
@@ -98,6 +99,21 @@ type API interface {
 	//
 	// Errors: errs.ZaloAPIError
 	BlockUser(ctx context.Context, userID string) (api.BlockUserResponse, error)
+	// Broadcast sends the same message content to many threads.
+	//
+	// Params:
+	//   - ctx - cancel/deadline control
+	//   - targets - threads to send the message to
+	//   - message - message content
+	//   - options - concurrency, rate limit and checkpoint options (optional)
+	//
+	// Note:
+	//   - Attachments are uploaded once per thread type and reused for every target of that type.
+	//   - Per-target failures are reported in the response instead of aborting the broadcast.
+	//   - Pass the returned checkpoint back in options to resume an interrupted broadcast.
+	//
+	// Errors: errs.ZaloAPIError, api.ErrBroadcastTargetsEmpty, api.ErrMessageContentEmpty, api.ErrQuoteWithoutText
+	Broadcast(ctx context.Context, targets []api.BroadcastTarget, message api.MessageContent, options *api.BroadcastOptions) (*api.BroadcastResponse, error)
 	// ChangeGroupOwner changes the owner of a group.
 	//
 	// Params:
@@ -246,6 +262,22 @@ type API interface {
 	//
 	// Errors: errs.ZaloAPIError
 	DisableGroupLink(ctx context.Context, groupID string) (api.DisableGroupLinkResponse, error)
+	// DownloadAttachment streams the photo, file, voice or video of a received message to w.
+	//
+	// Params:
+	//   - ctx - cancel/deadline control
+	//   - msg - received user or group message
+	//   - w - destination of the attachment data
+	//
+	// Note:
+	//   - Photos are downloaded in the best available quality.
+	//   - The size is checked against the message metadata when it is known. On a
+	//     mismatch after streaming, w has already received the data.
+	//
+	// Errors:
+	//   - errs.ZaloAPIError
+	//   - api.ErrNoAttachment, api.ErrDownloadSizeMismatch, api.ErrUnsupportedMessageKind
+	DownloadAttachment(ctx context.Context, msg model.Message, w io.Writer) (*api.DownloadAttachmentResponse, error)
 	// EnableGroupLink enables and creates a new group link.
 	//
 	// Params:
@@ -727,6 +759,21 @@ type API interface {
 	//
 	// Errors: errs.ZaloAPIError
 	ResetHiddenChatPIN(ctx context.Context) (api.ResetHiddenChatPINResponse, error)
+	// ResumeUploadAttachment sends the chunks of an interrupted UploadAttachment call
+	// that were not acknowledged yet.
+	//
+	// Params:
+	//   - ctx - cancel/deadline control
+	//   - state - state from the api.UploadIncompleteError of the failed call, updated in place
+	//   - options - progress callback, see UploadAttachmentWithOptions
+	//   - sources - the original sources in the same order, optional when every unfinished file was a path
+	//
+	// Note: Returns the results of every finished file, including the ones finished before.
+	//
+	// Errors:
+	//   - errs.ZaloAPIError, api.UploadIncompleteError
+	//   - api.ErrUploadStateEmpty, api.ErrUploadSourceMismatch, api.ErrUploadSourceMissing
+	ResumeUploadAttachment(ctx context.Context, state *api.UploadState, options api.UploadAttachmentOptions, sources ...model.AttachmentSource) (api.UploadAttachmentResponse, error)
 	// ReuseAvatar reuses an existing avatar from the avatar list.
 	//
 	// Params:
@@ -811,6 +858,16 @@ type API interface {
 	//
 	// Errors: errs.ZaloAPIError
 	SendLink(ctx context.Context, threadID string, threadType model.ThreadType, options api.SendLinkOptions) (*api.SendLinkResponse, error)
+	// SendLocation shares a location to a user or group.
+	//
+	// Params:
+	//   - ctx - cancel/deadline control
+	//   - threadID - ID of the user or group to send the location to
+	//   - threadType - thread type
+	//   - options - coordinates, optional title and address
+	//
+	// Errors: errs.ZaloAPIError, api.ErrInvalidLocation
+	SendLocation(ctx context.Context, threadID string, threadType model.ThreadType, options api.SendLocationOptions) (*api.SendLocationResponse, error)
 	// SendMessage sends a message to a thread.
 	//
 	// Params:
@@ -819,7 +876,9 @@ type API interface {
 	//   - threadType - thread type
 	//   - message - message content
 	//
-	// Errors: errs.ZaloAPIError, errs.ErrMissingImageMetadataGetter
+	// Note: A quote is sent with the text, so a message quoting another needs Msg.
+	//
+	// Errors: errs.ZaloAPIError, errs.ErrMissingImageMetadataGetter, api.ErrQuoteWithoutText
 	SendMessage(ctx context.Context, threadID string, threadType model.ThreadType, message api.MessageContent) (*api.SendMessageResponse, error)
 	// SendReport sends a report to Zalo.
 	//
@@ -882,6 +941,26 @@ type API interface {
 	//      - 720x1280 (HD): width 720px, height 1280px
 	//      - 1440x2560 (2K): width 1440px, height 2560px
 	SendVideo(ctx context.Context, threadID string, threadType model.ThreadType, options api.SendVideoOptions) (*api.SendVideoResponse, error)
+	// SendVideoFile uploads a local video and its thumbnail, then sends it to a user or group.
+	//
+	// Params:
+	//   - ctx - cancel/deadline control
+	//   - threadID - ID of the user or group to send the video to
+	//   - threadType - thread type
+	//   - options - video source, thumbnail and optional metadata
+	//
+	// Note:
+	//   - Missing width, height, duration and thumbnail are taken from options.Probe,
+	//     which reads mp4 headers by default. Probe errors are returned, and so is
+	//     api.ErrMissingVideoMetadata when the metadata is still zero.
+	//   - A thumbnail is required. The default probe can't extract one, so set
+	//     options.Thumbnail unless the probe returns a frame.
+	//   - Like UploadAttachment, this waits for the upload callback delivered by the listener.
+	//
+	// Errors:
+	//   - errs.ZaloAPIError, errs.ErrSourceEmpty, errs.ErrFileContentUnavailable
+	//   - api.ErrMissingVideoThumbnail, api.ErrMissingVideoMetadata, api.UploadIncompleteError
+	SendVideoFile(ctx context.Context, threadID string, threadType model.ThreadType, options api.SendVideoFileOptions) (*api.SendVideoResponse, error)
 	// SendVoice sends a voice message to a user or group.
 	//
 	// Params:
@@ -890,7 +969,12 @@ type API interface {
 	//   - threadType - thread type
 	//   - options - voice message options
 	//
-	// Errors: errs.ZaloAPIError
+	// Note: When options.VoiceURL is empty, options.Voice is uploaded first. Like
+	// UploadAttachment, this waits for the upload callback delivered by the listener.
+	//
+	// Errors:
+	//   - errs.ZaloAPIError, errs.ErrFileContentUnavailable
+	//   - api.ErrVoiceSourceEmpty, api.ErrInvalidVoiceExtension, api.UploadIncompleteError
 	SendVoice(ctx context.Context, threadID string, threadType model.ThreadType, options api.SendVoiceOptions) (*api.SendVoiceResponse, error)
 	// SetHiddenChat hides or unhides conversations.
 	//
@@ -1120,10 +1204,27 @@ type API interface {
 	//   - type - message type (User or Group)
 	//   - sources - path to files or attachment sources
 	//
+	// Note:
+	//   - Use UploadAttachmentWithOptions to receive per-chunk progress events.
+	//   - When a chunk fails the error is an *api.UploadIncompleteError whose state
+	//     can be persisted and passed to ResumeUploadAttachment.
+	//
 	// Errors:
-	//   - errs.ZaloAPIError, errs.ErrMissingImageMetadataGetter
+	//   - errs.ZaloAPIError, errs.ErrMissingImageMetadataGetter, api.UploadIncompleteError
 	//   - errs.ErrSourceEmpty, errs.ErrExceedMaxFile, errs.ErrInvalidExtension, errs.ErrExceedMaxFileSize
 	UploadAttachment(ctx context.Context, threadID string, threadType model.ThreadType, sources ...model.AttachmentSource) (api.UploadAttachmentResponse, error)
+	// UploadAttachmentWithOptions uploads one or more attachments to a thread,
+	// like UploadAttachment.
+	//
+	// Params:
+	//   - ctx - cancel/deadline control
+	//   - threadID - group or user ID
+	//   - type - message type (User or Group)
+	//   - options - OnProgress receives serialized per-chunk progress events
+	//   - sources - path to files or attachment sources
+	//
+	// Errors: same as UploadAttachment
+	UploadAttachmentWithOptions(ctx context.Context, threadID string, threadType model.ThreadType, options api.UploadAttachmentOptions, sources ...model.AttachmentSource) (api.UploadAttachmentResponse, error)
 	// UploadPhoto uploads a product photo for quick message,
 	// product catalog, or custom local storage.
 	//
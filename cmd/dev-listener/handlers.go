@@ -11,34 +11,26 @@ import (
 func (a *ListenerApp) handleMessage(msg model.Message) {
 	timestamp := time.Now().Format("15:04:05")
 
-	var content, displayName string
+	var (
+		content, displayName string
+		decoded              model.DecodedContent
+	)
 	switch m := msg.(type) {
 	case model.UserMessage:
-		switch {
-		case m.Data.Content.String != nil:
-			content = *m.Data.Content.String
-		case m.Data.Content.Attachment != nil:
-			content = fmt.Sprintf("[Attachment: %s]", m.Data.Content.Attachment.Title)
-		default:
-			content = "[Other content type]"
-		}
+		decoded = m.Decode()
 		displayName = m.Data.DName
 
 	case model.GroupMessage:
-		switch {
-		case m.Data.Content.String != nil:
-			content = *m.Data.Content.String
-		case m.Data.Content.Attachment != nil:
-			content = fmt.Sprintf("[Attachment: %s]", m.Data.Content.Attachment.Title)
-		default:
-			content = "[Other content type]"
-		}
+		decoded = m.Decode()
 		displayName = m.Data.DName
 
 	default:
 		content = "[Unknown message type]"
 		displayName = "Unknown"
 	}
+	if decoded != nil {
+		content = describeContent(decoded)
+	}
 
 	selfIndicator := ""
 	if msg.IsSelf() {
@@ -376,3 +368,39 @@ func (a *ListenerApp) handleFriend(ev model.FriendEvent) {
 		}
 	}
 }
+
+func describeContent(c model.DecodedContent) string {
+	switch c := c.(type) {
+	case model.TextContent:
+		return c.Text
+	case model.PhotoContent:
+		return fmt.Sprintf("[Photo %dx%d] %s", c.Width, c.Height, c.Caption)
+	case model.FileContent:
+		return fmt.Sprintf("[File: %s, %d bytes]", c.Name, c.Size)
+	case model.StickerContent:
+		return fmt.Sprintf("[Sticker: %d, category %d]", c.ID, c.CategoryID)
+	case model.GIFContent:
+		return "[GIF]"
+	case model.VoiceContent:
+		return fmt.Sprintf("[Voice: %dms]", c.Duration)
+	case model.VideoContent:
+		return fmt.Sprintf("[Video %dx%d, %dms]", c.Width, c.Height, c.Duration)
+	case model.LinkContent:
+		return fmt.Sprintf("[Link: %s]", c.URL)
+	case model.LocationContent:
+		return fmt.Sprintf("[Location: %f, %f]", c.Latitude, c.Longitude)
+	case model.ContactCardContent:
+		return fmt.Sprintf("[Contact: %s]", c.UserID)
+	case model.BankCardContent:
+		return fmt.Sprintf("[Bank card: %s]", c.AccountNumber)
+	case model.PollContent:
+		return fmt.Sprintf("[Poll %d: %s]", c.PollID, c.Question)
+	case model.TodoContent:
+		return fmt.Sprintf("[Todo: %s]", c.Content)
+	case model.RecommendedContent:
+		return fmt.Sprintf("[Recommended: %s]", c.Action)
+	case model.UnknownContent:
+		return fmt.Sprintf("[%s]", c.MsgType)
+	}
+	return "[Other content type]"
+}
@@ -0,0 +1,237 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Amrakk/zcago/errs"
+)
+
+var ErrUnterminatedQuote = errs.NewZCA("unterminated quote", "command.parse")
+
+// ArgType is the type an argument is parsed into.
+type ArgType int
+
+const (
+	ArgString ArgType = iota
+	ArgInt
+	ArgFloat
+	ArgBool     // true/false, yes/no, on/off, 1/0
+	ArgDuration // Go duration such as 90s or 1h30m
+	ArgRest     // The remaining text, unparsed, must be the last argument
+)
+
+func (t ArgType) String() string {
+	switch t {
+	case ArgInt:
+		return "int"
+	case ArgFloat:
+		return "number"
+	case ArgBool:
+		return "bool"
+	case ArgDuration:
+		return "duration"
+	case ArgRest:
+		return "text"
+	}
+	return "string"
+}
+
+type Arg struct {
+	Name        string
+	Type        ArgType
+	Optional    bool // Optional arguments must come after the required ones
+	Description string
+}
+
+func (a Arg) usage() string {
+	name := a.Name
+	if a.Type == ArgRest {
+		name += "..."
+	}
+	if a.Optional {
+		return "[" + name + "]"
+	}
+	return "<" + name + ">"
+}
+
+// Args holds the parsed arguments of a command call.
+type Args struct {
+	values map[string]any
+	raw    []string
+}
+
+// Has reports whether the argument was given.
+func (a Args) Has(name string) bool {
+	_, ok := a.values[name]
+	return ok
+}
+
+// Raw returns the tokens read from the arguments, with quotes removed. The text
+// of an ArgRest argument is split on whitespace without handling quotes.
+func (a Args) Raw() []string { return a.raw }
+
+func (a Args) String(name string) string {
+	v, _ := a.values[name].(string)
+	return v
+}
+
+func (a Args) Int(name string) int64 {
+	v, _ := a.values[name].(int64)
+	return v
+}
+
+func (a Args) Float(name string) float64 {
+	v, _ := a.values[name].(float64)
+	return v
+}
+
+func (a Args) Bool(name string) bool {
+	v, _ := a.values[name].(bool)
+	return v
+}
+
+func (a Args) Duration(name string) time.Duration {
+	v, _ := a.values[name].(time.Duration)
+	return v
+}
+
+// lexer splits its input on whitespace one token at a time, so that ArgRest can
+// take the remaining text as is. A single or double quote starting a token groups
+// words up to the matching quote, and a backslash escapes the next character,
+// except inside single quotes. Quotes inside a word, as in don't, are kept.
+type lexer struct {
+	input string
+	pos   int // Byte offset of the next character to read
+}
+
+// next returns the next token, ok is false once the input is consumed.
+func (l *lexer) next() (tok string, ok bool, err error) {
+	var (
+		cur    strings.Builder
+		start  = -1 // Offset of the token, -1 until it starts
+		quote  rune
+		escape bool
+	)
+
+	for i, r := range l.input[l.pos:] {
+		i += l.pos
+		switch {
+		case escape:
+			cur.WriteRune(r)
+			escape = false
+		case r == '\\' && quote != '\'':
+			escape = true
+			if start < 0 {
+				start = i
+			}
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case (r == '"' || r == '\'') && start < 0:
+			quote = r
+			start = i
+		case unicode.IsSpace(r):
+			if start >= 0 {
+				l.pos = i
+				return cur.String(), true, nil
+			}
+		default:
+			cur.WriteRune(r)
+			if start < 0 {
+				start = i
+			}
+		}
+	}
+	l.pos = len(l.input)
+
+	if quote != 0 {
+		return "", false, ErrUnterminatedQuote
+	}
+	if start < 0 {
+		return "", false, nil
+	}
+	return cur.String(), true, nil
+}
+
+// rest returns the input not read yet, without surrounding whitespace.
+func (l *lexer) rest() string {
+	s := strings.TrimSpace(l.input[l.pos:])
+	l.pos = len(l.input)
+	return s
+}
+
+// parseArgs reads the arguments following the command name in input according
+// to specs. The text of an ArgRest argument is not tokenized.
+func parseArgs(specs []Arg, input string) (Args, error) {
+	args := Args{values: make(map[string]any, len(specs))}
+	l := &lexer{input: input}
+
+	for _, spec := range specs {
+		if spec.Type == ArgRest {
+			rest := l.rest()
+			if rest == "" {
+				if !spec.Optional {
+					return args, fmt.Errorf("missing argument %s", spec.usage())
+				}
+				continue
+			}
+			args.raw = append(args.raw, strings.Fields(rest)...)
+			args.values[spec.Name] = rest
+			break
+		}
+
+		tok, ok, err := l.next()
+		if err != nil {
+			return args, err
+		}
+		if !ok {
+			if !spec.Optional {
+				return args, fmt.Errorf("missing argument %s", spec.usage())
+			}
+			continue
+		}
+		args.raw = append(args.raw, tok)
+
+		v, err := convertArg(spec.Type, tok)
+		if err != nil {
+			return args, fmt.Errorf("invalid %s %q: expected %s", spec.usage(), tok, spec.Type)
+		}
+		args.values[spec.Name] = v
+	}
+
+	tok, ok, err := l.next()
+	if err != nil {
+		return args, err
+	}
+	if ok {
+		return args, fmt.Errorf("unexpected argument %q", tok)
+	}
+	return args, nil
+}
+
+func convertArg(typ ArgType, s string) (any, error) {
+	switch typ {
+	case ArgInt:
+		return strconv.ParseInt(s, 10, 64)
+	case ArgFloat:
+		return strconv.ParseFloat(s, 64)
+	case ArgBool:
+		switch strings.ToLower(s) {
+		case "true", "yes", "on", "1":
+			return true, nil
+		case "false", "no", "off", "0":
+			return false, nil
+		}
+		return nil, strconv.ErrSyntax
+	case ArgDuration:
+		return time.ParseDuration(s)
+	}
+	return s, nil
+}
@@ -0,0 +1,359 @@
+// Package command parses prefixed chat commands such as `/remind 10m "stand up"`
+// out of incoming messages and runs the matching handler after checking its
+// permissions and cooldowns.
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/Amrakk/zcago/api"
+	"github.com/Amrakk/zcago/errs"
+	"github.com/Amrakk/zcago/model"
+	"github.com/Amrakk/zcago/router"
+)
+
+var ErrDuplicateCommand = errs.NewZCA("command name or alias already registered", "command.Register")
+
+// API is the subset of zcago.API used by the bot.
+type API interface {
+	SendMessage(ctx context.Context, threadID string, threadType model.ThreadType, message api.MessageContent) (*api.SendMessageResponse, error)
+	GetGroupInfo(ctx context.Context, groupID ...string) (*api.GetGroupInfoResponse, error)
+}
+
+type Options struct {
+	Prefix string   // Defaults to "/"
+	Allow  []string // When set, only these user IDs may use commands
+	Deny   []string // User IDs never allowed to use commands
+
+	RoleCacheTTL time.Duration // How long group roles are kept, defaults to 5 minutes
+	DisableHelp  bool          // Don't register the built-in help command
+
+	// OnError receives the errors of command calls. By default usage, permission
+	// and cooldown errors are replied to the sender and others are logged.
+	OnError func(c *Context, err error)
+}
+
+type Command struct {
+	Name        string
+	Aliases     []string
+	Description string
+	Args        []Arg
+
+	Role  Role     // Minimum group role, commands requiring more than RoleMember only run in groups
+	Allow []string // When set, only these user IDs may use the command
+	Deny  []string // User IDs not allowed to use the command
+
+	UserCooldown   time.Duration // Delay before the same user can use the command again
+	ThreadCooldown time.Duration // Delay before the command can be used again in the same thread
+
+	Run func(c *Context) error
+}
+
+// usage returns the synopsis of the command, e.g. "/remind <after> <text...>".
+func (cmd *Command) usage(prefix string) string {
+	parts := []string{prefix + cmd.Name}
+	for _, a := range cmd.Args {
+		parts = append(parts, a.usage())
+	}
+	return strings.Join(parts, " ")
+}
+
+// UsageError is returned when the arguments of a command can't be parsed.
+type UsageError struct {
+	Usage string
+	Err   error
+}
+
+func (e UsageError) Error() string { return e.Err.Error() + "\nusage: " + e.Usage }
+func (e UsageError) Unwrap() error { return e.Err }
+
+type Bot struct {
+	mu sync.RWMutex
+
+	api      API
+	opts     Options
+	commands []*Command
+	names    map[string]*Command
+
+	roles     *roleCache
+	cooldowns *cooldowns
+}
+
+func New(a API, opts *Options) *Bot {
+	b := &Bot{
+		api:       a,
+		names:     make(map[string]*Command),
+		cooldowns: &cooldowns{until: make(map[cooldownKey]time.Time)},
+	}
+	if opts != nil {
+		b.opts = *opts
+	}
+	if b.opts.Prefix == "" {
+		b.opts.Prefix = "/"
+	}
+	if b.opts.RoleCacheTTL <= 0 {
+		b.opts.RoleCacheTTL = 5 * time.Minute
+	}
+	if b.opts.OnError == nil {
+		b.opts.OnError = defaultOnError
+	}
+	b.roles = &roleCache{api: a, ttl: b.opts.RoleCacheTTL, groups: make(map[string]groupRoles)}
+
+	if !b.opts.DisableHelp {
+		_ = b.Register(&Command{
+			Name:        "help",
+			Description: "List the commands or describe one of them",
+			Args:        []Arg{{Name: "command", Optional: true}},
+			Run: func(c *Context) error {
+				_, err := c.Reply(b.Help(c.Args.String("command")))
+				return err
+			},
+		})
+	}
+	return b
+}
+
+// Register adds commands. Names and aliases are case-insensitive and must be
+// unique, nothing is registered when one of them collides.
+func (b *Bot) Register(cmds ...*Command) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	batch := make(map[string]struct{})
+	for _, cmd := range cmds {
+		for _, k := range append([]string{cmd.Name}, cmd.Aliases...) {
+			key := strings.ToLower(k)
+			if _, ok := b.names[key]; ok {
+				return errs.WrapZCA(k, "command.Register", ErrDuplicateCommand)
+			}
+			if _, ok := batch[key]; ok {
+				return errs.WrapZCA(k, "command.Register", ErrDuplicateCommand)
+			}
+			batch[key] = struct{}{}
+		}
+	}
+
+	for _, cmd := range cmds {
+		for _, k := range append([]string{cmd.Name}, cmd.Aliases...) {
+			b.names[strings.ToLower(k)] = cmd
+		}
+		b.commands = append(b.commands, cmd)
+	}
+	return nil
+}
+
+// Help lists the commands with their usage, or describes a single command
+// along with its arguments when name is set.
+func (b *Bot) Help(name string) string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var sb strings.Builder
+	if name != "" {
+		cmd, ok := b.names[strings.ToLower(strings.TrimPrefix(name, b.opts.Prefix))]
+		if !ok {
+			return "unknown command " + name
+		}
+
+		sb.WriteString(cmd.usage(b.opts.Prefix))
+		if cmd.Description != "" {
+			sb.WriteString("\n" + cmd.Description)
+		}
+		if len(cmd.Aliases) > 0 {
+			sb.WriteString("\naliases: " + strings.Join(cmd.Aliases, ", "))
+		}
+		for _, a := range cmd.Args {
+			fmt.Fprintf(&sb, "\n  %s (%s)", a.Name, a.Type)
+			if a.Description != "" {
+				sb.WriteString(": " + a.Description)
+			}
+		}
+		return sb.String()
+	}
+
+	cmds := slices.Clone(b.commands)
+	slices.SortFunc(cmds, func(x, y *Command) int { return strings.Compare(x.Name, y.Name) })
+	for i, cmd := range cmds {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(cmd.usage(b.opts.Prefix))
+		if cmd.Description != "" {
+			sb.WriteString(" - " + cmd.Description)
+		}
+	}
+	return sb.String()
+}
+
+// Attach registers the bot on r so that it handles every incoming message. Cached
+// group roles are dropped when the admins of a group change.
+func (b *Bot) Attach(r *router.Router) {
+	r.OnMessage(func(ctx context.Context, msg model.Message) error {
+		b.Handle(ctx, msg)
+		return nil
+	})
+
+	forget := func(_ context.Context, ev model.GroupEvent) error {
+		b.roles.forget(ev.ThreadID())
+		return nil
+	}
+	r.OnGroupEvent(model.GroupEventTypeAddAdmin, forget)
+	r.OnGroupEvent(model.GroupEventTypeRemoveAdmin, forget)
+	r.OnGroupEvent(model.GroupEventTypeUpdate, forget)
+}
+
+// Handle runs the command contained in msg, if any, and reports whether msg
+// was a known command. Errors are passed to Options.OnError.
+func (b *Bot) Handle(ctx context.Context, msg model.Message) bool {
+	c, ok := b.lookup(ctx, msg)
+	if !ok {
+		return false
+	}
+
+	if err := b.run(c); err != nil {
+		b.opts.OnError(c, err)
+	}
+	return true
+}
+
+func (b *Bot) lookup(ctx context.Context, msg model.Message) (*Context, bool) {
+	// With SelfListen, the replies of the bot come back and could trigger commands
+	if msg.IsSelf() {
+		return nil, false
+	}
+
+	var data model.TMessage
+	switch m := msg.(type) {
+	case model.UserMessage:
+		data = m.Data
+	case model.GroupMessage:
+		data = m.Data.TMessage
+	default:
+		return nil, false
+	}
+	if data.Content.String == nil {
+		return nil, false
+	}
+
+	text, ok := strings.CutPrefix(strings.TrimSpace(*data.Content.String), b.opts.Prefix)
+	if !ok || text == "" {
+		return nil, false
+	}
+	name, rest := text, ""
+	if i := strings.IndexFunc(text, unicode.IsSpace); i >= 0 {
+		name, rest = text[:i], text[i:]
+	}
+	name = strings.ToLower(name)
+
+	b.mu.RLock()
+	cmd, ok := b.names[name]
+	b.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+
+	return &Context{
+		ctx:        ctx,
+		bot:        b,
+		Command:    cmd,
+		Name:       name,
+		Message:    msg,
+		Data:       data,
+		ThreadID:   msg.ThreadID(),
+		ThreadType: msg.Type(),
+		SenderID:   data.UIDFrom,
+		input:      rest,
+	}, true
+}
+
+func (b *Bot) run(c *Context) error {
+	if err := b.allowed(c); err != nil {
+		return err
+	}
+
+	var err error
+	c.Args, err = parseArgs(c.Command.Args, c.input)
+	if err != nil {
+		return UsageError{Usage: c.Command.usage(b.opts.Prefix), Err: err}
+	}
+
+	if err := b.cooldowns.take(c.Command, c.SenderID, c.ThreadID); err != nil {
+		return err
+	}
+
+	return c.Command.Run(c)
+}
+
+func defaultOnError(c *Context, err error) {
+	var (
+		usage    UsageError
+		cooldown CooldownError
+	)
+	switch {
+	case errors.As(err, &usage), errors.As(err, &cooldown):
+		_, _ = c.Reply(err.Error())
+	case errors.Is(err, ErrPermissionDenied):
+		_, _ = c.Reply(ErrPermissionDenied.Message)
+	default:
+		log.Printf("command: %s%s failed: %v", c.bot.opts.Prefix, c.Command.Name, err)
+	}
+}
+
+// Context is passed to command handlers. It knows the thread the command was
+// sent in and quotes the command message when replying.
+type Context struct {
+	ctx context.Context
+	bot *Bot
+
+	Command    *Command
+	Name       string // Name or alias the command was called with
+	Message    model.Message
+	Data       model.TMessage
+	ThreadID   string
+	ThreadType model.ThreadType
+	SenderID   string
+	Args       Args
+
+	input string // Text following the command name
+}
+
+func (c *Context) Context() context.Context { return c.ctx }
+
+// Reply sends text to the thread, quoting the command message.
+func (c *Context) Reply(text string) (*api.SendMessageResponse, error) {
+	return c.Send(api.MessageContent{Msg: text, Quote: c.quote()})
+}
+
+// Send sends content to the thread the command came from.
+func (c *Context) Send(content api.MessageContent) (*api.SendMessageResponse, error) {
+	return c.bot.api.SendMessage(c.ctx, c.ThreadID, c.ThreadType, content)
+}
+
+// Role returns the role of the sender in the group, RoleMember in 1:1 threads.
+func (c *Context) Role() (Role, error) {
+	if c.ThreadType != model.ThreadTypeGroup {
+		return RoleMember, nil
+	}
+	return c.bot.roles.role(c.ctx, c.ThreadID, c.SenderID)
+}
+
+func (c *Context) quote() *api.SendMessageQuote {
+	return &api.SendMessageQuote{
+		MsgID:       c.Data.MsgID,
+		CliMsgID:    c.Data.CliMsgID,
+		MsgType:     c.Data.MsgType,
+		UIDFrom:     c.Data.UIDFrom,
+		Content:     c.Data.Content,
+		PropertyExt: c.Data.PropertyExt,
+		TS:          c.Data.TS,
+		TTL:         c.Data.TTL,
+	}
+}
@@ -0,0 +1,62 @@
+package command
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CooldownError is returned when a command is used again before its cooldown ends.
+type CooldownError struct {
+	Command   string
+	Remaining time.Duration
+	PerThread bool // The thread cooldown is the one still running
+}
+
+func (e CooldownError) Error() string {
+	return fmt.Sprintf("please wait %s before using %s again", e.Remaining.Round(time.Second), e.Command)
+}
+
+type cooldownKey struct {
+	command string
+	scope   string // "u:" + user ID or "t:" + thread ID
+}
+
+type cooldowns struct {
+	mu    sync.Mutex
+	until map[cooldownKey]time.Time
+}
+
+// take starts the user and thread cooldowns of cmd, or reports the one still running.
+func (cd *cooldowns) take(cmd *Command, userID, threadID string) error {
+	now := time.Now()
+	userKey := cooldownKey{command: cmd.Name, scope: "u:" + userID}
+	threadKey := cooldownKey{command: cmd.Name, scope: "t:" + threadID}
+
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+
+	if until, ok := cd.until[threadKey]; ok && now.Before(until) {
+		return CooldownError{Command: cmd.Name, Remaining: until.Sub(now), PerThread: true}
+	}
+	if until, ok := cd.until[userKey]; ok && now.Before(until) {
+		return CooldownError{Command: cmd.Name, Remaining: until.Sub(now)}
+	}
+
+	if cmd.UserCooldown > 0 {
+		cd.until[userKey] = now.Add(cmd.UserCooldown)
+	}
+	if cmd.ThreadCooldown > 0 {
+		cd.until[threadKey] = now.Add(cmd.ThreadCooldown)
+	}
+
+	// Expired entries are dropped from time to time to keep the map bounded
+	if len(cd.until) > 1024 {
+		for k, until := range cd.until {
+			if now.After(until) {
+				delete(cd.until, k)
+			}
+		}
+	}
+	return nil
+}
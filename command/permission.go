@@ -0,0 +1,112 @@
+package command
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Amrakk/zcago/errs"
+	"github.com/Amrakk/zcago/model"
+)
+
+var ErrPermissionDenied = errs.NewZCA("you are not allowed to use this command", "command.Run")
+
+// Role is the rank of a user in a group. Users of 1:1 threads are members.
+type Role int
+
+const (
+	RoleMember Role = iota
+	RoleDeputy
+	RoleOwner
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleDeputy:
+		return "deputy"
+	case RoleOwner:
+		return "owner"
+	}
+	return "member"
+}
+
+type groupRoles struct {
+	owner     string
+	deputies  []string
+	fetchedAt time.Time
+}
+
+// roleCache resolves group roles through GetGroupInfo, keeping them for ttl.
+type roleCache struct {
+	mu     sync.Mutex
+	api    API
+	ttl    time.Duration
+	groups map[string]groupRoles
+}
+
+func (c *roleCache) role(ctx context.Context, groupID, userID string) (Role, error) {
+	c.mu.Lock()
+	roles, ok := c.groups[groupID]
+	c.mu.Unlock()
+
+	if !ok || time.Since(roles.fetchedAt) > c.ttl {
+		resp, err := c.api.GetGroupInfo(ctx, groupID)
+		if err != nil {
+			return RoleMember, errs.WrapZCA("failed to get group info", "command.Role", err)
+		}
+		info, ok := resp.GridInfoMap[groupID]
+		if !ok {
+			return RoleMember, errs.NewZCA("group not found", "command.Role")
+		}
+
+		roles = groupRoles{owner: info.CreatorID, deputies: info.AdminIDs, fetchedAt: time.Now()}
+		c.mu.Lock()
+		c.groups[groupID] = roles
+		c.mu.Unlock()
+	}
+
+	switch {
+	case userID == roles.owner:
+		return RoleOwner, nil
+	case slices.Contains(roles.deputies, userID):
+		return RoleDeputy, nil
+	}
+	return RoleMember, nil
+}
+
+func (c *roleCache) forget(groupID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.groups, groupID)
+}
+
+// allowed checks the deny and allow lists of the bot and the command, then the
+// group role required by the command.
+func (b *Bot) allowed(c *Context) error {
+	user := c.SenderID
+	if slices.Contains(b.opts.Deny, user) || slices.Contains(c.Command.Deny, user) {
+		return ErrPermissionDenied
+	}
+	if len(b.opts.Allow) > 0 && !slices.Contains(b.opts.Allow, user) {
+		return ErrPermissionDenied
+	}
+	if len(c.Command.Allow) > 0 && !slices.Contains(c.Command.Allow, user) {
+		return ErrPermissionDenied
+	}
+
+	if c.Command.Role == RoleMember {
+		return nil
+	}
+	if c.ThreadType != model.ThreadTypeGroup {
+		return ErrPermissionDenied
+	}
+	role, err := c.Role()
+	if err != nil {
+		return err
+	}
+	if role < c.Command.Role {
+		return ErrPermissionDenied
+	}
+	return nil
+}
@@ -37,6 +37,7 @@ const (
 const (
 	GIFChunkSize         = 512 * KiB // 0.5 MiB
 	AttachmentChunksSize = 2 * MiB   // 2 MiB
+	MaxChunksInFlight    = 4         // Chunk requests running at once during an upload
 )
 
 var SupportedImageExtensions = []string{"jpg", "jpeg", "png", "webp"}
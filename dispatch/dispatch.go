@@ -0,0 +1,195 @@
+// Package dispatch serializes sends per thread while running different threads in parallel,
+// so multi-part replies to the same thread are delivered in the order they were submitted.
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Amrakk/zcago/api"
+	"github.com/Amrakk/zcago/errs"
+	"github.com/Amrakk/zcago/model"
+)
+
+var ErrClosed = errs.NewZCA("dispatcher is closed", "dispatch.Submit")
+
+// Sender is the subset of zcago.API used by SendMessage.
+type Sender interface {
+	SendMessage(ctx context.Context, threadID string, threadType model.ThreadType, message api.MessageContent) (*api.SendMessageResponse, error)
+}
+
+type Options struct {
+	MaxThreads int // Maximum number of threads sending at the same time, 0 means unlimited
+}
+
+type Result struct {
+	Response *api.SendMessageResponse
+	Err      error
+}
+
+type threadKey struct {
+	id  string
+	typ model.ThreadType
+}
+
+type task struct {
+	ctx  context.Context
+	fn   func(ctx context.Context) error
+	res  chan error
+	done chan struct{} // Closed once the task finished, after res was sent
+}
+
+type threadQueue struct {
+	tasks []task
+}
+
+type Dispatcher struct {
+	mu sync.Mutex
+
+	sender Sender
+	sem    chan struct{}
+	queues map[threadKey]*threadQueue
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func New(sender Sender, opts *Options) *Dispatcher {
+	d := &Dispatcher{
+		sender: sender,
+		queues: make(map[threadKey]*threadQueue),
+	}
+	if opts != nil && opts.MaxThreads > 0 {
+		d.sem = make(chan struct{}, opts.MaxThreads)
+	}
+	return d
+}
+
+// Submit queues fn behind the pending tasks of the thread. The returned channel
+// receives the error of fn, or ctx.Err() if ctx is done before fn gets its turn.
+// A panic in fn is recovered and received as an error.
+func (d *Dispatcher) Submit(ctx context.Context, threadID string, threadType model.ThreadType, fn func(ctx context.Context) error) <-chan error {
+	res := make(chan error, 1)
+	key := threadKey{id: threadID, typ: threadType}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		res <- ErrClosed
+		return res
+	}
+
+	q, ok := d.queues[key]
+	if !ok {
+		q = &threadQueue{}
+		d.queues[key] = q
+
+		d.wg.Add(1)
+		go d.drain(key, q)
+	}
+	q.tasks = append(q.tasks, task{ctx: ctx, fn: fn, res: res, done: make(chan struct{})})
+
+	return res
+}
+
+// SendMessage queues a message for the thread and returns a channel receiving its result.
+func (d *Dispatcher) SendMessage(ctx context.Context, threadID string, threadType model.ThreadType, message api.MessageContent) <-chan Result {
+	out := make(chan Result, 1)
+
+	var resp *api.SendMessageResponse
+	done := d.Submit(ctx, threadID, threadType, func(ctx context.Context) error {
+		var err error
+		resp, err = d.sender.SendMessage(ctx, threadID, threadType, message)
+		return err
+	})
+
+	go func() {
+		err := <-done
+		out <- Result{Response: resp, Err: err}
+	}()
+
+	return out
+}
+
+// Depth returns the number of tasks of the thread not yet finished, including the running one.
+func (d *Dispatcher) Depth(threadID string, threadType model.ThreadType) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if q, ok := d.queues[threadKey{id: threadID, typ: threadType}]; ok {
+		return len(q.tasks)
+	}
+	return 0
+}
+
+// Wait blocks until every task queued for the thread before the call has finished.
+// Tasks submitted afterwards are not waited for.
+func (d *Dispatcher) Wait(ctx context.Context, threadID string, threadType model.ThreadType) error {
+	d.mu.Lock()
+	q, ok := d.queues[threadKey{id: threadID, typ: threadType}]
+	if !ok || len(q.tasks) == 0 {
+		d.mu.Unlock()
+		return nil
+	}
+	// Tasks of a thread run in order, so the last one finishing means all did
+	last := q.tasks[len(q.tasks)-1].done
+	d.mu.Unlock()
+
+	select {
+	case <-last:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close rejects new tasks and waits for the queued ones to finish.
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) drain(key threadKey, q *threadQueue) {
+	defer d.wg.Done()
+
+	if d.sem != nil {
+		d.sem <- struct{}{}
+		defer func() { <-d.sem }()
+	}
+
+	for {
+		d.mu.Lock()
+		if len(q.tasks) == 0 {
+			delete(d.queues, key)
+			d.mu.Unlock()
+			return
+		}
+		t := q.tasks[0]
+		d.mu.Unlock()
+
+		if err := t.ctx.Err(); err != nil {
+			t.res <- err
+		} else {
+			t.res <- runTask(t)
+		}
+
+		d.mu.Lock()
+		q.tasks = q.tasks[1:]
+		d.mu.Unlock()
+		close(t.done)
+	}
+}
+
+// runTask runs the task and turns a panic into its error, so the queue keeps draining.
+func runTask(t task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errs.NewZCA(fmt.Sprintf("task panicked: %v", r), "dispatch.Submit")
+		}
+	}()
+	return t.fn(t.ctx)
+}
@@ -8,7 +8,7 @@ import (
 
 	"github.com/Amrakk/zcago"
 	"github.com/Amrakk/zcago/api"
-	"github.com/Amrakk/zcago/model"
+	"github.com/Amrakk/zcago/router"
 )
 
 func main() {
@@ -24,35 +24,22 @@ func main() {
 	if err := ln.Start(ctx, true); err != nil {
 		log.Fatalf("listener start error: %v", err)
 	}
-
 	defer stop()
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case m, ok := <-ln.Message():
-			if !ok {
-				return
-			}
-
-			var msg string
-			switch v := m.(type) {
-			case model.UserMessage:
-				if v.Data.Content.String != nil {
-					msg = *v.Data.Content.String
-				}
-			case model.GroupMessage:
-				if v.Data.Content.String != nil {
-					msg = *v.Data.Content.String
-				}
-			default:
-				continue
-			}
-
-			log.Printf("%s %s\n", m.ThreadID(), msg)
-			if _, err := a.SendMessage(ctx, m.ThreadID(), m.Type(), api.MessageContent{Msg: msg}); err != nil {
-				log.Printf("send failed (thread %s): %v", m.ThreadID(), err)
-			}
+
+	r := router.New(ln, nil)
+	r.Use(router.Recover())
+	r.Handle(router.KindMessage, func(ctx context.Context, ev router.Event) error {
+		threadID, threadType, _ := ev.Thread()
+		msg := ev.Text()
+		log.Printf("%s %s\n", threadID, msg)
+
+		if _, err := a.SendMessage(ctx, threadID, threadType, api.MessageContent{Msg: msg}); err != nil {
+			log.Printf("send failed (thread %s): %v", threadID, err)
 		}
+		return nil
+	}, router.OfMessageType("webchat"))
+
+	if err := r.Run(ctx); err != nil && ctx.Err() == nil {
+		log.Printf("router stopped: %v", err)
 	}
 }
@@ -0,0 +1,144 @@
+package httpx
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"sync"
+)
+
+// StreamForm is a multipart form whose body is read lazily from the source.
+type StreamForm struct {
+	Body          io.Reader
+	Header        http.Header
+	ContentLength int64
+}
+
+// ChunkedForm splits a source into multipart forms of chunkSize bytes without
+// buffering the whole file.
+//
+// When the source implements io.ReaderAt every chunk is streamed straight from it
+// and chunks may be requested in any order or concurrently. Otherwise the source
+// is read sequentially, so chunks must be requested in increasing order and only
+// the current chunk is held in memory. Chunks skipped over are discarded.
+type ChunkedForm struct {
+	fieldName   string
+	fileName    string
+	contentType string
+	size        int64
+	chunkSize   int64
+
+	ra io.ReaderAt
+
+	mu   sync.Mutex
+	seq  io.Reader
+	next int
+}
+
+func NewChunkedForm(fieldName string, source io.Reader, size, chunkSize int64, opts ...Opt) (*ChunkedForm, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("chunked form needs a positive size, got %d", size)
+	}
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("chunked form needs a positive chunk size, got %d", chunkSize)
+	}
+
+	o := BuildOptions{FileName: "blob"}
+	for _, f := range opts {
+		f(&o)
+	}
+
+	c := &ChunkedForm{
+		fieldName: fieldName,
+		fileName:  o.FileName,
+		size:      size,
+		chunkSize: chunkSize,
+	}
+
+	if ra, ok := source.(io.ReaderAt); ok {
+		c.ra = ra
+		c.contentType, _ = detectContentType(o.FileName, o.ContentType, io.NewSectionReader(ra, 0, size))
+	} else {
+		c.contentType, c.seq = detectContentType(o.FileName, o.ContentType, source)
+	}
+
+	return c, nil
+}
+
+// Size returns the total number of bytes of the source.
+func (c *ChunkedForm) Size() int64 { return c.size }
+
+// TotalChunk returns the number of chunks the source is split into.
+func (c *ChunkedForm) TotalChunk() int {
+	return int((c.size + c.chunkSize - 1) / c.chunkSize)
+}
+
+// ChunkLen returns the number of source bytes carried by chunk i.
+func (c *ChunkedForm) ChunkLen(i int) int64 {
+	return min(c.chunkSize, c.size-int64(i)*c.chunkSize)
+}
+
+// Seekable reports whether chunks can be requested out of order.
+func (c *ChunkedForm) Seekable() bool { return c.ra != nil }
+
+// Chunk returns the form of chunk i (0-based).
+func (c *ChunkedForm) Chunk(i int) (*StreamForm, error) {
+	if i < 0 || i >= c.TotalChunk() {
+		return nil, fmt.Errorf("chunk %d out of range [0, %d)", i, c.TotalChunk())
+	}
+
+	n := c.ChunkLen(i)
+
+	var data io.Reader
+	if c.ra != nil {
+		data = io.NewSectionReader(c.ra, int64(i)*c.chunkSize, n)
+	} else {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if i < c.next {
+			return nil, fmt.Errorf("chunk %d requested out of order, next is %d", i, c.next)
+		}
+		if skip := int64(i-c.next) * c.chunkSize; skip > 0 {
+			if _, err := io.CopyN(io.Discard, c.seq, skip); err != nil {
+				return nil, err
+			}
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(c.seq, buf); err != nil {
+			return nil, err
+		}
+		c.next = i + 1
+		data = bytes.NewReader(buf)
+	}
+
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, c.fieldName, c.fileName))
+	h.Set("Content-Type", c.contentType)
+
+	if _, err := w.CreatePart(h); err != nil {
+		return nil, err
+	}
+	head := bytes.Clone(b.Bytes())
+	b.Reset()
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	tail := bytes.Clone(b.Bytes())
+
+	hdr := make(http.Header, 1)
+	hdr.Set("Content-Type", w.FormDataContentType())
+
+	return &StreamForm{
+		Body:          io.MultiReader(bytes.NewReader(head), data, bytes.NewReader(tail)),
+		Header:        hdr,
+		ContentLength: int64(len(head)) + n + int64(len(tail)),
+	}, nil
+}
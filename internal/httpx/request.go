@@ -18,11 +18,12 @@ import (
 )
 
 type RequestOptions struct {
-	Method  string
-	Headers http.Header
-	Query   url.Values
-	Body    io.Reader
-	Raw     bool
+	Method        string
+	Headers       http.Header
+	Query         url.Values
+	Body          io.Reader
+	ContentLength int64 // Length of a streamed Body, 0 lets net/http decide
+	Raw           bool
 }
 
 func BuildFormBody(data map[string]string) io.Reader {
@@ -41,15 +42,15 @@ type FormData struct {
 type BuildOptions struct {
 	FileName    string // default: "blob"
 	ContentType string // default: auto-detect then fallback to octet-stream
-	ChunkSize   int64  // >0 => build chunks; else single form
 }
 
 type Opt func(*BuildOptions)
 
 func WithFileName(name string) Opt  { return func(o *BuildOptions) { o.FileName = name } }
 func WithContentType(ct string) Opt { return func(o *BuildOptions) { o.ContentType = ct } }
-func WithChunkSize(n int64) Opt     { return func(o *BuildOptions) { o.ChunkSize = n } }
 
+// BuildFormData buffers source into a single multipart form.
+// Use NewChunkedForm to stream large files in chunks instead.
 func BuildFormData(fieldName string, source io.Reader, opts ...Opt) ([]*FormData, error) {
 	o := BuildOptions{FileName: "blob"}
 	for _, f := range opts {
@@ -57,9 +58,6 @@ func BuildFormData(fieldName string, source io.Reader, opts ...Opt) ([]*FormData
 	}
 
 	ct, src := detectContentType(o.FileName, o.ContentType, source)
-	if o.ChunkSize > 0 {
-		return buildChunks(fieldName, ct, o.FileName, src, o.ChunkSize)
-	}
 	fd, err := buildOne(fieldName, ct, o.FileName, src)
 	if err != nil {
 		return nil, err
@@ -109,31 +107,6 @@ func buildOne(fieldName, contentType, fileName string, src io.Reader) (*FormData
 	return &FormData{Body: body, Header: hdr}, nil
 }
 
-func buildChunks(fieldName, contentType, fileName string, src io.Reader, chunkSize int64) ([]*FormData, error) {
-	if chunkSize <= 0 {
-		return nil, fmt.Errorf("chunkSize must be > 0")
-	}
-	var out []*FormData
-	r := src
-	for {
-		lr := &io.LimitedReader{R: r, N: chunkSize}
-		fd, err := buildOne(fieldName, contentType, fileName, lr)
-		if err != nil {
-			return nil, err
-		}
-
-		consumed := chunkSize - lr.N
-		if consumed == 0 {
-			break
-		}
-		out = append(out, fd)
-		if consumed < chunkSize {
-			break
-		}
-	}
-	return out, nil
-}
-
 func buildRequest(ctx context.Context, sc session.MutableContext, urlStr string, opt *RequestOptions) (*http.Request, error) {
 	headers := http.Header{}
 
@@ -164,6 +137,9 @@ func buildRequest(ctx context.Context, sc session.MutableContext, urlStr string,
 		return nil, err
 	}
 	req.Header = headers
+	if opt != nil && opt.ContentLength > 0 {
+		req.ContentLength = opt.ContentLength
+	}
 
 	return req, nil
 }
@@ -0,0 +1,190 @@
+// Package mp4x reads the duration and video dimensions of ISO base media files
+// (mp4, m4a, mov) from their moov box, without decoding any media.
+package mp4x
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+var ErrNoMovieBox = errors.New("mp4x: moov box not found")
+
+type Info struct {
+	Duration time.Duration
+	Width    int // Width of the first video track, 0 for audio only files
+	Height   int
+}
+
+// Probe reads the movie header and track headers of the file.
+func Probe(r io.ReaderAt, size int64) (Info, error) {
+	moov, ok, err := findBox(r, 0, size, "moov")
+	if err != nil {
+		return Info{}, err
+	}
+	if !ok {
+		return Info{}, ErrNoMovieBox
+	}
+
+	var info Info
+
+	if mvhd, ok, err := findBox(r, moov.start, moov.end, "mvhd"); err != nil {
+		return Info{}, err
+	} else if ok {
+		if info.Duration, err = readMovieDuration(r, mvhd); err != nil {
+			return Info{}, err
+		}
+	}
+
+	off := moov.start
+	for off < moov.end {
+		trak, ok, err := findBox(r, off, moov.end, "trak")
+		if err != nil {
+			return Info{}, err
+		}
+		if !ok {
+			break
+		}
+		off = trak.end
+
+		tkhd, ok, err := findBox(r, trak.start, trak.end, "tkhd")
+		if err != nil {
+			return Info{}, err
+		}
+		if !ok {
+			continue
+		}
+		w, h, err := readTrackSize(r, tkhd)
+		if err != nil {
+			return Info{}, err
+		}
+		if w > 0 && h > 0 {
+			info.Width, info.Height = w, h
+			break
+		}
+	}
+
+	return info, nil
+}
+
+// box is the payload range of a box, header excluded.
+type box struct {
+	start, end int64
+}
+
+func findBox(r io.ReaderAt, off, end int64, typ string) (box, bool, error) {
+	var hdr [16]byte
+	for off+8 <= end {
+		if _, err := r.ReadAt(hdr[:8], off); err != nil {
+			return box{}, false, err
+		}
+
+		size := int64(binary.BigEndian.Uint32(hdr[:4]))
+		headerLen := int64(8)
+		switch size {
+		case 0:
+			size = end - off
+		case 1:
+			if _, err := r.ReadAt(hdr[8:16], off+8); err != nil {
+				return box{}, false, err
+			}
+			size = int64(binary.BigEndian.Uint64(hdr[8:16]))
+			headerLen = 16
+		}
+		if size < headerLen || off+size > end {
+			return box{}, false, errors.New("mp4x: malformed box")
+		}
+
+		if string(hdr[4:8]) == typ {
+			return box{start: off + headerLen, end: off + size}, true, nil
+		}
+		off += size
+	}
+	return box{}, false, nil
+}
+
+// The mvhd and tkhd boxes start with a version byte and 3 bytes of flags, the
+// version selects between 32 and 64 bit times.
+const (
+	mvhdLenV0 = 20 // Up to the 32 bit duration
+	mvhdLenV1 = 32 // Up to the 64 bit duration
+	tkhdLenV0 = 84 // Up to the 16.16 height
+	tkhdLenV1 = 96
+)
+
+var (
+	errMalformedMovieHeader = errors.New("mp4x: malformed mvhd box")
+	errMalformedTrackHeader = errors.New("mp4x: malformed tkhd box")
+	errDurationOverflow     = errors.New("mp4x: duration out of range")
+)
+
+func readMovieDuration(r io.ReaderAt, b box) (time.Duration, error) {
+	if b.end-b.start < mvhdLenV0 {
+		return 0, errMalformedMovieHeader
+	}
+	buf := make([]byte, min(b.end-b.start, mvhdLenV1))
+	if _, err := r.ReadAt(buf, b.start); err != nil {
+		return 0, err
+	}
+
+	var timescale, duration uint64
+	switch buf[0] {
+	case 0:
+		timescale = uint64(binary.BigEndian.Uint32(buf[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(buf[16:20]))
+	case 1:
+		if len(buf) < mvhdLenV1 {
+			return 0, errMalformedMovieHeader
+		}
+		timescale = uint64(binary.BigEndian.Uint32(buf[20:24]))
+		duration = binary.BigEndian.Uint64(buf[24:32])
+	default:
+		return 0, errMalformedMovieHeader
+	}
+	if timescale == 0 {
+		return 0, nil
+	}
+
+	return scaleDuration(duration, timescale)
+}
+
+// scaleDuration converts duration units of 1/timescale seconds, failing when
+// the result does not fit a time.Duration.
+func scaleDuration(duration, timescale uint64) (time.Duration, error) {
+	secs, rem := duration/timescale, duration%timescale
+	if secs > uint64(math.MaxInt64/time.Second)-1 {
+		return 0, errDurationOverflow
+	}
+	// rem < timescale < 2^32, so rem*time.Second can't overflow
+	return time.Duration(secs)*time.Second + time.Duration(rem*uint64(time.Second)/timescale), nil
+}
+
+func readTrackSize(r io.ReaderAt, b box) (int, int, error) {
+	if b.end-b.start < tkhdLenV0 {
+		return 0, 0, errMalformedTrackHeader
+	}
+	buf := make([]byte, min(b.end-b.start, tkhdLenV1))
+	if _, err := r.ReadAt(buf, b.start); err != nil {
+		return 0, 0, err
+	}
+
+	// Width and height are 16.16 fixed point values closing the box
+	var off int
+	switch buf[0] {
+	case 0:
+		off = tkhdLenV0 - 8
+	case 1:
+		off = tkhdLenV1 - 8
+	default:
+		return 0, 0, errMalformedTrackHeader
+	}
+	if len(buf) < off+8 {
+		return 0, 0, errMalformedTrackHeader
+	}
+
+	w := binary.BigEndian.Uint32(buf[off : off+4])
+	h := binary.BigEndian.Uint32(buf[off+4 : off+8])
+	return int(w >> 16), int(h >> 16), nil
+}
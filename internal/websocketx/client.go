@@ -53,6 +53,8 @@ type client struct {
 	errChan    chan error
 	closedChan chan CloseInfo
 	writeQueue chan writeRequest
+
+	blockOnFull bool
 }
 
 var _ Client = (*client)(nil)
@@ -66,6 +68,7 @@ func Dial(ctx context.Context, url string, opt *Options) (*client, error) {
 		if opt.MsgBuf > 0 {
 			cfg.MsgBuf = opt.MsgBuf
 		}
+		cfg.BlockOnFull = opt.BlockOnFull
 		if opt.ErrBuf > 0 {
 			cfg.ErrBuf = opt.ErrBuf
 		}
@@ -96,6 +99,8 @@ func Dial(ctx context.Context, url string, opt *Options) (*client, error) {
 		errChan:    make(chan error, cfg.ErrBuf),
 		closedChan: make(chan CloseInfo, 1),
 		writeQueue: make(chan writeRequest, cfg.WriteBuf),
+
+		blockOnFull: cfg.BlockOnFull,
 	}
 
 	// Reader
@@ -165,7 +170,7 @@ func (c *client) readLoop(ctx context.Context) {
 
 		switch typ {
 		case websocket.MessageText, websocket.MessageBinary:
-			c.handleMsg(Message{Type: typ, Data: data})
+			c.handleMsg(ctx, Message{Type: typ, Data: data})
 		}
 	}
 }
@@ -213,7 +218,15 @@ func (c *client) shutdown(ci CloseInfo, sendCloseFrame bool) {
 	})
 }
 
-func (c *client) handleMsg(m Message) {
+func (c *client) handleMsg(ctx context.Context, m Message) {
+	if c.blockOnFull {
+		select {
+		case c.msgChan <- m:
+		case <-ctx.Done():
+		}
+		return
+	}
+
 	select {
 	case c.msgChan <- m:
 	default:
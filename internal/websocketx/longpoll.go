@@ -0,0 +1,313 @@
+package websocketx
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+// Long-polling carries the same frames as the websocket over plain HTTP. It is
+// experimental: the framing below is specific to this package, not the one of
+// the official clients, and needs an endpoint implementing it.
+//
+//   - frames are read by polling the endpoint with GET requests, each answered
+//     once frames are available with a body of frames, every one prefixed by its
+//     length as a big-endian uint32, or with 204 No Content when the poll timed out
+//   - a frame is written by POSTing it as the body of a request
+const (
+	longPollMaxFailures = 5
+	longPollMaxBackoff  = 30 * time.Second
+)
+
+type longPollClient struct {
+	url        string
+	header     http.Header
+	httpClient *http.Client
+
+	connCtx context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	once    sync.Once
+
+	msgChan    chan Message
+	errChan    chan error
+	closedChan chan CloseInfo
+
+	blockOnFull bool
+}
+
+var _ Client = (*longPollClient)(nil)
+
+// DialLongPoll opens a long-polling connection to url, an http(s) endpoint
+// serving the frames of the websocket. It is used where websockets are blocked.
+func DialLongPoll(ctx context.Context, url string, opt *Options) (*longPollClient, error) {
+	cfg := defaultOptions()
+	if opt != nil {
+		if opt.Header != nil {
+			cfg.Header = opt.Header
+		}
+		if opt.HTTPClient != nil {
+			cfg.HTTPClient = opt.HTTPClient
+		}
+		if opt.MsgBuf > 0 {
+			cfg.MsgBuf = opt.MsgBuf
+		}
+		cfg.BlockOnFull = opt.BlockOnFull
+		if opt.ErrBuf > 0 {
+			cfg.ErrBuf = opt.ErrBuf
+		}
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+
+	if _, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil); err != nil {
+		return nil, err
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cl := &longPollClient{
+		url:        url,
+		header:     cfg.Header,
+		httpClient: cfg.HTTPClient,
+		connCtx:    cctx,
+		cancel:     cancel,
+		msgChan:    make(chan Message, cfg.MsgBuf),
+		errChan:    make(chan error, cfg.ErrBuf),
+		closedChan: make(chan CloseInfo, 1),
+
+		blockOnFull: cfg.BlockOnFull,
+	}
+
+	cl.wg.Add(1)
+	go cl.pollLoop(cctx)
+
+	return cl, nil
+}
+
+func (c *longPollClient) Messages() <-chan Message { return c.msgChan }
+func (c *longPollClient) Errors() <-chan error     { return c.errChan }
+func (c *longPollClient) Closed() <-chan CloseInfo { return c.closedChan }
+
+func (c *longPollClient) Write(ctx context.Context, typ websocket.MessageType, data []byte) error {
+	if c.connCtx.Err() != nil {
+		return net.ErrClosed
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if typ == websocket.MessageText {
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	} else {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("long-polling write failed: %s", resp.Status)
+	}
+	return nil
+}
+
+func (c *longPollClient) WriteText(ctx context.Context, s string) error {
+	return c.Write(ctx, websocket.MessageText, []byte(s))
+}
+
+func (c *longPollClient) Close(code int, reason string) {
+	c.shutdown(CloseInfo{Code: code, Reason: reason})
+}
+
+func (c *longPollClient) pollLoop(ctx context.Context) {
+	defer c.wg.Done()
+
+	failures := 0
+	for ctx.Err() == nil {
+		frames, err := c.poll(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			var status statusError
+			if errors.As(err, &status) && status.fatal() {
+				c.shutdown(CloseInfo{Code: int(websocket.StatusPolicyViolation), Reason: status.Error(), Err: err})
+				return
+			}
+
+			failures++
+			if failures >= longPollMaxFailures {
+				c.shutdown(CloseInfo{Code: int(websocket.StatusAbnormalClosure), Reason: "long-polling failed", Err: err})
+				return
+			}
+			c.handleErr(err)
+
+			backoff := min(time.Second<<(failures-1), longPollMaxBackoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			continue
+		}
+
+		failures = 0
+		for _, f := range frames {
+			c.handleMsg(ctx, Message{Type: websocket.MessageBinary, Data: f})
+		}
+	}
+}
+
+// poll waits for the next frames. A poll timing out on the server side
+// returns no frames and no error.
+func (c *longPollClient) poll(ctx context.Context) ([][]byte, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return nil, nil
+	case resp.StatusCode/100 != 2:
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, statusError{code: resp.StatusCode, status: resp.Status}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return splitFrames(body)
+}
+
+func (c *longPollClient) newRequest(ctx context.Context, method string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.url, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	return req, nil
+}
+
+func splitFrames(body []byte) ([][]byte, error) {
+	var frames [][]byte
+	for len(body) > 0 {
+		if len(body) < 4 {
+			return frames, errors.New("long-polling: truncated frame length")
+		}
+		n := binary.BigEndian.Uint32(body[:4])
+		body = body[4:]
+		if uint64(n) > uint64(len(body)) {
+			return frames, errors.New("long-polling: truncated frame")
+		}
+		frames = append(frames, body[:n])
+		body = body[n:]
+	}
+	return frames, nil
+}
+
+func (c *longPollClient) shutdown(ci CloseInfo) {
+	c.once.Do(func() {
+		c.pushClose(ci)
+		c.cancel()
+		go func() {
+			c.wg.Wait()
+			close(c.msgChan)
+			close(c.errChan)
+			close(c.closedChan)
+		}()
+	})
+}
+
+func (c *longPollClient) handleMsg(ctx context.Context, m Message) {
+	if c.blockOnFull {
+		select {
+		case c.msgChan <- m:
+		case <-ctx.Done():
+		}
+		return
+	}
+
+	select {
+	case c.msgChan <- m:
+	default:
+		select { // drop oldest
+		case <-c.msgChan:
+		default:
+		}
+		select { // retry once, non-blocking
+		case c.msgChan <- m:
+		default:
+		}
+	}
+}
+
+func (c *longPollClient) handleErr(err error) {
+	select {
+	case c.errChan <- err:
+	default:
+		select { // drop oldest
+		case <-c.errChan:
+		default:
+		}
+		select { // retry once, non-blocking
+		case c.errChan <- err:
+		default:
+		}
+	}
+}
+
+func (c *longPollClient) pushClose(ci CloseInfo) {
+	for {
+		select {
+		case c.closedChan <- ci:
+			return
+		default:
+			select {
+			case <-c.closedChan:
+			default:
+			}
+		}
+	}
+}
+
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e statusError) Error() string { return "long-polling: " + e.status }
+
+// fatal reports whether polling again can't succeed, e.g. the session expired.
+func (e statusError) fatal() bool {
+	switch e.code {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusGone:
+		return true
+	}
+	return false
+}
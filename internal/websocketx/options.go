@@ -10,6 +10,10 @@ type Options struct {
 	MsgBuf     int
 	ErrBuf     int
 	WriteBuf   int
+
+	// BlockOnFull makes the reader wait for room in Messages instead of dropping
+	// the oldest frame, so a slow consumer stalls the connection.
+	BlockOnFull bool
 }
 
 func defaultOptions() Options {
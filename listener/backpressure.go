@@ -0,0 +1,216 @@
+package listener
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/Amrakk/zcago/errs"
+)
+
+var ErrSpillUnsupported = errs.NewZCA("events of this channel cannot be spilled to disk", "listener.Configure")
+
+// BackpressurePolicy decides what happens to an event when the channel it is
+// emitted on is full.
+type BackpressurePolicy uint8
+
+const (
+	// BackpressureDropOldest discards the oldest buffered event to make room.
+	BackpressureDropOldest BackpressurePolicy = iota
+	// BackpressureDropNewest discards the event being emitted.
+	BackpressureDropNewest
+	// BackpressureBlock waits for room. Reading the websocket stalls meanwhile,
+	// so every other channel stops receiving too and no frame is dropped by the
+	// connection. A consumer blocking longer than the ping interval delays pongs,
+	// which can make the idle timeout reconnect.
+	BackpressureBlock
+	// BackpressureSpillToDisk appends overflowing events to a temporary file and
+	// delivers them in order as room frees up.
+	BackpressureSpillToDisk
+)
+
+func (p BackpressurePolicy) String() string {
+	switch p {
+	case BackpressureDropOldest:
+		return "drop-oldest"
+	case BackpressureDropNewest:
+		return "drop-newest"
+	case BackpressureBlock:
+		return "block"
+	case BackpressureSpillToDisk:
+		return "spill-to-disk"
+	}
+	return fmt.Sprintf("BackpressurePolicy(%d)", uint8(p))
+}
+
+// Policies sets the backpressure policy of each event channel. The zero value
+// of every field is BackpressureDropOldest.
+type Policies struct {
+	Message           BackpressurePolicy
+	OldMessages       BackpressurePolicy
+	Typing            BackpressurePolicy
+	SeenMessages      BackpressurePolicy
+	DeliveredMessages BackpressurePolicy
+	Reaction          BackpressurePolicy
+	OldReactions      BackpressurePolicy
+	Undo              BackpressurePolicy
+	UploadAttachment  BackpressurePolicy
+	Friend            BackpressurePolicy
+	Group             BackpressurePolicy
+	Raw               BackpressurePolicy
+}
+
+// blocks reports whether any channel uses BackpressureBlock.
+func (p Policies) blocks() bool {
+	for _, policy := range []BackpressurePolicy{
+		p.Message, p.OldMessages, p.Typing, p.SeenMessages, p.DeliveredMessages, p.Reaction,
+		p.OldReactions, p.Undo, p.UploadAttachment, p.Friend, p.Group, p.Raw,
+	} {
+		if policy == BackpressureBlock {
+			return true
+		}
+	}
+	return false
+}
+
+// DroppedEventError is sent on the Error channel each time an event is dropped.
+type DroppedEventError struct {
+	Channel string
+	Policy  BackpressurePolicy
+	Total   uint64 // Events dropped on Channel so far
+	Err     error  // Set when spilling to disk failed
+}
+
+func (e DroppedEventError) Error() string {
+	msg := fmt.Sprintf("listener: dropped event on %s channel (%s, %d dropped so far)", e.Channel, e.Policy, e.Total)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e DroppedEventError) Unwrap() error { return e.Err }
+
+// outlet is an event channel along with its backpressure policy and drop counter.
+type outlet[T any] struct {
+	name   string
+	ch     chan T
+	policy BackpressurePolicy
+	spill  *spillQueue[T]
+	drops  atomic.Uint64
+	report func(ctx context.Context, err error)
+
+	kind        EventKind
+	publish     func(ctx context.Context, kind EventKind, value any) // Fans events out to subscribers
+	publishOnly bool                                                 // Skips ch, set by WithSubscribeOnly
+
+	filter func(obj T) (T, bool) // Drops or trims events ahead of emit, e.g. duplicates
+}
+
+func newOutlet[T any](name string, size int, policy BackpressurePolicy, codec *spillCodec[T], spillDir string) (*outlet[T], error) {
+	o := &outlet[T]{
+		name:   name,
+		ch:     make(chan T, size),
+		policy: policy,
+	}
+
+	if policy == BackpressureSpillToDisk {
+		if codec == nil {
+			return nil, errs.WrapZCA("invalid backpressure policy for the "+name+" channel", "listener.Configure", ErrSpillUnsupported)
+		}
+		o.spill = &spillQueue[T]{
+			dir:   spillDir,
+			name:  name,
+			codec: codec,
+			drop:  o.drop,
+		}
+	}
+
+	return o, nil
+}
+
+// closeSpill deletes the spill file of the outlet, if any.
+func (o *outlet[T]) closeSpill() {
+	if o.spill != nil {
+		o.spill.close()
+	}
+}
+
+func (o *outlet[T]) drop(ctx context.Context, cause error) {
+	total := o.drops.Add(1)
+	if o.report != nil {
+		o.report(ctx, DroppedEventError{Channel: o.name, Policy: o.policy, Total: total, Err: cause})
+	}
+}
+
+// emit publishes obj to the subscribers and delivers it into the outlet channel.
+//
+// Behavior:
+//   - The outlet filter runs first and may drop or trim obj.
+//   - With WithSubscribeOnly, obj only reaches the subscribers.
+//   - If the channel has buffer space, obj is sent immediately.
+//   - If the channel is full, the outlet policy decides:
+//     BackpressureDropOldest drops the oldest value in the channel (non-blocking
+//     receive) and retries once, dropping obj if the channel is still full;
+//     BackpressureDropNewest drops obj; BackpressureBlock waits for room or ctx;
+//     BackpressureSpillToDisk appends obj to the spill file, delivered later in order.
+//   - Every dropped event is counted and reported on the Error channel.
+//
+// Apart from BackpressureBlock, this ensures that slow or absent receivers do not
+// block the sender, at the cost of possibly overwriting or dropping events.
+func emit[T any](ctx context.Context, o *outlet[T], obj T) {
+	if o.filter != nil {
+		var ok bool
+		if obj, ok = o.filter(obj); !ok {
+			return
+		}
+	}
+
+	if o.publish != nil {
+		o.publish(ctx, o.kind, obj)
+	}
+	if o.publishOnly {
+		return
+	}
+
+	switch o.policy {
+	case BackpressureBlock:
+		select {
+		case <-ctx.Done():
+		case o.ch <- obj:
+		}
+
+	case BackpressureDropNewest:
+		select {
+		case <-ctx.Done():
+		case o.ch <- obj:
+		default:
+			o.drop(ctx, nil)
+		}
+
+	case BackpressureSpillToDisk:
+		if err := o.spill.push(ctx, o.ch, obj); err != nil {
+			o.drop(ctx, err)
+		}
+
+	default:
+		select {
+		case <-ctx.Done():
+			return
+		case o.ch <- obj:
+			return
+		default:
+		}
+
+		select {
+		case <-o.ch:
+			o.drop(ctx, nil)
+		default:
+		}
+		select {
+		case o.ch <- obj:
+		default:
+			o.drop(ctx, nil)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package listener
 
 import (
 	"context"
+	"sync/atomic"
 
 	"github.com/Amrakk/zcago/internal/websocketx"
 	"github.com/Amrakk/zcago/model"
@@ -24,9 +25,11 @@ type Buffers struct {
 	Friend            int
 	Group             int
 	CipherKey         int
+	Raw               int
 }
 
-func defaultBuffers() Buffers {
+// DefaultBuffers returns the channel buffer sizes used when none are configured.
+func DefaultBuffers() Buffers {
 	return Buffers{
 		Connected:         1,
 		Disconnected:      4,
@@ -44,6 +47,7 @@ func defaultBuffers() Buffers {
 		Friend:            32,
 		Group:             32,
 		CipherKey:         4,
+		Raw:               64,
 	}
 }
 
@@ -52,50 +56,99 @@ type channels struct {
 	Disconnected      chan websocketx.CloseInfo
 	Closed            chan websocketx.CloseInfo
 	Error             chan error
-	Message           chan model.Message
-	OldMessages       chan model.OldMessages
-	Reaction          chan model.Reaction
-	OldReactions      chan model.OldReactions
-	Typing            chan model.Typing
-	DeliveredMessages chan []model.DeliveredMessage
-	SeenMessages      chan []model.SeenMessage
-	Undo              chan model.Undo
-	UploadAttachment  chan model.UploadAttachment
-	Friend            chan model.FriendEvent
-	Group             chan model.GroupEvent
+	Message           *outlet[model.Message]
+	OldMessages       *outlet[model.OldMessages]
+	Reaction          *outlet[model.Reaction]
+	OldReactions      *outlet[model.OldReactions]
+	Typing            *outlet[model.Typing]
+	DeliveredMessages *outlet[[]model.DeliveredMessage]
+	SeenMessages      *outlet[[]model.SeenMessage]
+	Undo              *outlet[model.Undo]
+	UploadAttachment  *outlet[model.UploadAttachment]
+	Friend            *outlet[model.FriendEvent]
+	Group             *outlet[model.GroupEvent]
 	CipherKey         chan string
+	Raw               *outlet[RawFrame]
+
+	// Frames are only emitted on Raw when enabled by WithRawFrames
+	rawFrames bool
+
+	// Events dropped because the Error channel was full
+	errorDrops atomic.Uint64
+}
+
+// closeSpills stops draining the outlets spilled to disk and deletes their files.
+func (c *channels) closeSpills() {
+	c.Message.closeSpill()
+	c.OldMessages.closeSpill()
+	c.Reaction.closeSpill()
+	c.OldReactions.closeSpill()
+	c.Typing.closeSpill()
+	c.DeliveredMessages.closeSpill()
+	c.SeenMessages.closeSpill()
+	c.Undo.closeSpill()
+	c.UploadAttachment.closeSpill()
+	c.Friend.closeSpill()
+	c.Group.closeSpill()
+	c.Raw.closeSpill()
 }
 
 func (ln *listener) Connected() <-chan struct{}                { return ln.ch.Connected }
 func (ln *listener) Disconnected() <-chan websocketx.CloseInfo { return ln.ch.Disconnected }
 func (ln *listener) Closed() <-chan websocketx.CloseInfo       { return ln.ch.Closed }
 func (ln *listener) Error() <-chan error                       { return ln.ch.Error }
-func (ln *listener) Message() <-chan model.Message             { return ln.ch.Message }
-func (ln *listener) OldMessages() <-chan model.OldMessages     { return ln.ch.OldMessages }
-func (ln *listener) Reaction() <-chan model.Reaction           { return ln.ch.Reaction }
-func (ln *listener) OldReactions() <-chan model.OldReactions   { return ln.ch.OldReactions }
-func (ln *listener) Typing() <-chan model.Typing               { return ln.ch.Typing }
+func (ln *listener) Message() <-chan model.Message             { return ln.ch.Message.ch }
+func (ln *listener) OldMessages() <-chan model.OldMessages     { return ln.ch.OldMessages.ch }
+func (ln *listener) Reaction() <-chan model.Reaction           { return ln.ch.Reaction.ch }
+func (ln *listener) OldReactions() <-chan model.OldReactions   { return ln.ch.OldReactions.ch }
+func (ln *listener) Typing() <-chan model.Typing               { return ln.ch.Typing.ch }
 
 func (ln *listener) DeliveredMessages() <-chan []model.DeliveredMessage {
-	return ln.ch.DeliveredMessages
+	return ln.ch.DeliveredMessages.ch
+}
+func (ln *listener) SeenMessages() <-chan []model.SeenMessage { return ln.ch.SeenMessages.ch }
+func (ln *listener) Undo() <-chan model.Undo                  { return ln.ch.Undo.ch }
+func (ln *listener) UploadAttachment() <-chan model.UploadAttachment {
+	return ln.ch.UploadAttachment.ch
+}
+func (ln *listener) Friend() <-chan model.FriendEvent { return ln.ch.Friend.ch }
+func (ln *listener) Group() <-chan model.GroupEvent   { return ln.ch.Group.ch }
+func (ln *listener) CipherKey() <-chan string         { return ln.ch.CipherKey }
+
+// DroppedEvents returns the number of events dropped so far on each channel
+// by its backpressure policy, keyed by channel name.
+func (ln *listener) DroppedEvents() map[string]uint64 {
+	return map[string]uint64{
+		"Error":             ln.ch.errorDrops.Load(),
+		"Message":           ln.ch.Message.drops.Load(),
+		"OldMessages":       ln.ch.OldMessages.drops.Load(),
+		"Reaction":          ln.ch.Reaction.drops.Load(),
+		"OldReactions":      ln.ch.OldReactions.drops.Load(),
+		"Typing":            ln.ch.Typing.drops.Load(),
+		"DeliveredMessages": ln.ch.DeliveredMessages.drops.Load(),
+		"SeenMessages":      ln.ch.SeenMessages.drops.Load(),
+		"Undo":              ln.ch.Undo.drops.Load(),
+		"UploadAttachment":  ln.ch.UploadAttachment.drops.Load(),
+		"Friend":            ln.ch.Friend.drops.Load(),
+		"Group":             ln.ch.Group.drops.Load(),
+		"Raw":               ln.ch.Raw.drops.Load(),
+	}
 }
-func (ln *listener) SeenMessages() <-chan []model.SeenMessage        { return ln.ch.SeenMessages }
-func (ln *listener) Undo() <-chan model.Undo                         { return ln.ch.Undo }
-func (ln *listener) UploadAttachment() <-chan model.UploadAttachment { return ln.ch.UploadAttachment }
-func (ln *listener) Friend() <-chan model.FriendEvent                { return ln.ch.Friend }
-func (ln *listener) Group() <-chan model.GroupEvent                  { return ln.ch.Group }
-func (ln *listener) CipherKey() <-chan string                        { return ln.ch.CipherKey }
 
 func (ln *listener) emitError(ctx context.Context, err error) {
+	ln.publish(ctx, KindError, err)
 	select {
 	case <-ctx.Done():
 		return
 	case ln.ch.Error <- err:
 	default:
+		ln.ch.errorDrops.Add(1)
 	}
 }
 
 func (ln *listener) emitClosed(ctx context.Context, ci websocketx.CloseInfo) {
+	ln.setState(ctx, StateInfo{State: StateClosed, Reason: ci})
+	ln.publish(ctx, KindClosed, ci)
 	select {
 	case ln.ch.Closed <- ci:
 	case <-ctx.Done():
@@ -103,30 +156,3 @@ func (ln *listener) emitClosed(ctx context.Context, ci websocketx.CloseInfo) {
 	default:
 	}
 }
-
-// emit attempts to deliver obj into the provided channel without blocking.
-//
-// Behavior:
-//   - If the channel has buffer space, obj is sent immediately.
-//   - If the channel is full, the oldest value in the channel is dropped
-//     (non-blocking receive) and emit retries once to enqueue obj.
-//   - If the second attempt also fails (channel still full), obj is dropped.
-//
-// This policy ensures that slow or absent receivers do not block the sender,
-// at the cost of possibly overwriting or dropping messages.
-func emit[T any](ctx context.Context, ch chan T, obj T) {
-	select {
-	case <-ctx.Done():
-		return
-	case ch <- obj:
-	default:
-		select {
-		case <-ch:
-		default:
-		}
-		select {
-		case ch <- obj:
-		default:
-		}
-	}
-}
@@ -0,0 +1,136 @@
+package listener
+
+import (
+	"cmp"
+	"container/list"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Amrakk/zcago/model"
+)
+
+// DefaultDedupeSize is the number of event keys remembered by the
+// de-duplication stage when WithDedupe is given no size.
+const DefaultDedupeSize = 4096
+
+type dedupeEntry struct {
+	key     string
+	expires time.Time // Zero when the entry only leaves by eviction
+}
+
+// dedupeCache is a bounded LRU set of event keys whose entries may also expire.
+type dedupeCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	order *list.List // Most recently seen first
+	items map[string]*list.Element
+}
+
+func newDedupeCache(size int, ttl time.Duration) *dedupeCache {
+	if size <= 0 {
+		size = DefaultDedupeSize
+	}
+	return &dedupeCache{
+		size:  size,
+		ttl:   max(ttl, 0),
+		order: list.New(),
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+// seen reports whether any of keys was already recorded, then records them
+// all. Empty keys are ignored, and an event with no key is never a duplicate.
+func (c *dedupeCache) seen(keys ...string) bool {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dup := false
+	for _, k := range keys {
+		if k == "" {
+			continue
+		}
+
+		if el, ok := c.items[k]; ok {
+			e := el.Value.(*dedupeEntry)
+			if e.expires.IsZero() || now.Before(e.expires) {
+				dup = true
+			}
+			c.order.Remove(el)
+			delete(c.items, k)
+		}
+
+		e := &dedupeEntry{key: k}
+		if c.ttl > 0 {
+			e.expires = now.Add(c.ttl)
+		}
+		c.items[k] = c.order.PushFront(e)
+
+		for c.order.Len() > c.size {
+			oldest := c.order.Back()
+			c.order.Remove(oldest)
+			delete(c.items, oldest.Value.(*dedupeEntry).key)
+		}
+	}
+	return dup
+}
+
+// attachDedupe makes the Message, Reaction, Undo and DeliveredMessages outlets
+// skip the events already emitted.
+func attachDedupe(ch *channels, c *dedupeCache) {
+	ch.Message.filter = func(m model.Message) (model.Message, bool) {
+		return m, !c.seen(messageKeys(m)...)
+	}
+	ch.Reaction.filter = func(r model.Reaction) (model.Reaction, bool) {
+		return r, !c.seen(dedupeKey("r", cmp.Or(r.Data.ActionID, r.Data.MsgID)))
+	}
+	ch.Undo.filter = func(u model.Undo) (model.Undo, bool) {
+		return u, !c.seen(dedupeKey("u", cmp.Or(u.Data.MsgID, u.Data.ActionID)))
+	}
+	ch.DeliveredMessages.filter = func(dms []model.DeliveredMessage) ([]model.DeliveredMessage, bool) {
+		// A message is reported once per online device of the recipients
+		dms = slices.DeleteFunc(slices.Clone(dms), func(dm model.DeliveredMessage) bool {
+			return c.seen(deliveredKey(dm))
+		})
+		return dms, len(dms) > 0
+	}
+}
+
+func messageKeys(m model.Message) []string {
+	var data model.TMessage
+	switch m := m.(type) {
+	case model.UserMessage:
+		data = m.Data
+	case model.GroupMessage:
+		data = m.Data.TMessage
+	default:
+		return nil
+	}
+
+	return []string{dedupeKey("m", data.MsgID), dedupeKey("c", data.CliMsgID, data.UIDFrom)}
+}
+
+func deliveredKey(dm model.DeliveredMessage) string {
+	var data model.TDeliveredMessage
+	switch dm := dm.(type) {
+	case model.UserDeliveredMessage:
+		data = dm.Data
+	case model.GroupDeliveredMessage:
+		data = dm.Data.TDeliveredMessage
+	default:
+		return ""
+	}
+	return dedupeKey("d", data.MsgID, dm.ThreadID(), strings.Join(data.DeliveredUIDs, ","), strings.Join(data.SeenUIDs, ","))
+}
+
+// dedupeKey builds the key of id within scope, or returns "" when id is empty.
+func dedupeKey(kind, id string, scope ...string) string {
+	if id == "" {
+		return ""
+	}
+	return kind + ":" + strings.Join(append(scope, id), ":")
+}
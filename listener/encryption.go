@@ -43,7 +43,31 @@ func encodeFrame(p WSPayload) ([]byte, error) {
 	return buf, nil
 }
 
+// getCipherKey returns the key of the current connection. The key is written by
+// the reader goroutine and read by requests, so it is only accessed under mu.
+func (ln *listener) getCipherKey() string {
+	ln.mu.RLock()
+	defer ln.mu.RUnlock()
+	return ln.cipherKey
+}
+
+func (ln *listener) setCipherKey(key string) {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+	ln.cipherKey = key
+}
+
 func decodeEventData[T any](parsed BaseWSMessage, cipherKey string) (*WSMessage[T], error) {
+	payload, err := decodeEventPayload(parsed, cipherKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseJSON[T](payload)
+}
+
+// decodeEventPayload returns the decrypted JSON carried by the data of a frame.
+func decodeEventPayload(parsed BaseWSMessage, cipherKey string) ([]byte, error) {
 	data := parsed.Data
 
 	encType, err := extractEncryptionType(parsed)
@@ -52,7 +76,7 @@ func decodeEventData[T any](parsed BaseWSMessage, cipherKey string) (*WSMessage[
 	}
 
 	if encType == EncryptionTypeNone {
-		return parseJSON[T]([]byte(data))
+		return []byte(data), nil
 	}
 
 	payload, err := decodeAndDecrypt(data, encType, cipherKey)
@@ -71,7 +95,7 @@ func decodeEventData[T any](parsed BaseWSMessage, cipherKey string) (*WSMessage[
 		return nil, errs.NewZCA("payload is not valid UTF-8", "listener.decodeEventData")
 	}
 
-	return parseJSON[T](payload)
+	return payload, nil
 }
 
 func extractEncryptionType(parsed BaseWSMessage) (uint, error) {
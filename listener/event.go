@@ -0,0 +1,115 @@
+package listener
+
+import (
+	"time"
+
+	"github.com/Amrakk/zcago/model"
+)
+
+// EventKind names the channel an event is emitted on.
+type EventKind string
+
+const (
+	KindConnected         EventKind = "connected"
+	KindDisconnected      EventKind = "disconnected"
+	KindClosed            EventKind = "closed"
+	KindError             EventKind = "error"
+	KindMessage           EventKind = "message"
+	KindOldMessages       EventKind = "old_messages"
+	KindReaction          EventKind = "reaction"
+	KindOldReactions      EventKind = "old_reactions"
+	KindTyping            EventKind = "typing"
+	KindDeliveredMessages EventKind = "delivered_messages"
+	KindSeenMessages      EventKind = "seen_messages"
+	KindUndo              EventKind = "undo"
+	KindUploadAttachment  EventKind = "upload_attachment"
+	KindFriend            EventKind = "friend"
+	KindGroup             EventKind = "group"
+	KindCipherKey         EventKind = "cipher_key"
+	KindRaw               EventKind = "raw"
+	KindState             EventKind = "state"
+)
+
+// Event is a value emitted by the listener. Value holds the channel element,
+// e.g. a model.Message for KindMessage or a CloseInfo for KindClosed.
+type Event struct {
+	Kind     EventKind
+	Value    any
+	Received time.Time
+}
+
+// threaded is implemented by messages, typing, seen and delivered events.
+type threaded interface {
+	Type() model.ThreadType
+	ThreadID() string
+	IsSelf() bool
+}
+
+// Thread returns the thread the event belongs to. ok is false for events not
+// bound to a single thread, such as errors or batches of seen messages.
+func (e Event) Thread() (threadID string, threadType model.ThreadType, ok bool) {
+	switch v := e.Value.(type) {
+	case threaded:
+		return v.ThreadID(), v.Type(), true
+	case model.Reaction:
+		return v.ThreadID, v.Type, true
+	case model.Undo:
+		if v.IsGroup {
+			return v.ThreadID, model.ThreadTypeGroup, true
+		}
+		return v.ThreadID, model.ThreadTypeUser, true
+	case model.GroupEvent:
+		return v.ThreadID(), model.ThreadTypeGroup, true
+	case model.FriendEvent:
+		return v.ThreadID(), model.ThreadTypeUser, true
+	}
+	return "", 0, false
+}
+
+// IsSelf reports whether the event was triggered by the logged in account.
+func (e Event) IsSelf() bool {
+	switch v := e.Value.(type) {
+	case threaded:
+		return v.IsSelf()
+	case model.Reaction:
+		return v.IsSelf
+	case model.Undo:
+		return v.IsSelf
+	case model.GroupEvent:
+		return v.IsSelf()
+	case model.FriendEvent:
+		return v.IsSelf()
+	}
+	return false
+}
+
+// Message returns the message carried by a KindMessage event.
+func (e Event) Message() (model.TMessage, bool) {
+	switch v := e.Value.(type) {
+	case model.UserMessage:
+		return v.Data, true
+	case model.GroupMessage:
+		return v.Data.TMessage, true
+	}
+	return model.TMessage{}, false
+}
+
+// Text returns the content of a plain text message, "" for other events.
+func (e Event) Text() string {
+	if msg, ok := e.Message(); ok && msg.Content.String != nil {
+		return *msg.Content.String
+	}
+	return ""
+}
+
+// CloseInfo returns the close info of a KindDisconnected or KindClosed event.
+func (e Event) CloseInfo() (CloseInfo, bool) {
+	ci, ok := e.Value.(CloseInfo)
+	return ci, ok
+}
+
+// State returns the state entered in a KindState event.
+func (e Event) State() (StateInfo, bool) {
+	info, ok := e.Value.(StateInfo)
+	return info, ok
+}
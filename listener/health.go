@@ -0,0 +1,126 @@
+package listener
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Amrakk/zcago/internal/logger"
+)
+
+// closeDeadConnection closes a connection on which nothing was received for
+// too long, the listener then reconnects within the retry budget of the code.
+const closeDeadConnection = 4001
+
+// Health describes the liveness of the connection.
+type Health struct {
+	LastActivity time.Time     // When the last frame was received
+	LastPing     time.Time     // When the last ping was sent
+	RTT          time.Duration // Round trip of the last answered ping, 0 until one is answered
+}
+
+// health is updated by the reading and ping goroutines. Times are unix nanoseconds.
+type health struct {
+	lastInbound atomic.Int64
+	lastPing    atomic.Int64
+	rtt         atomic.Int64
+
+	mu       sync.Mutex
+	pingID   string    // req_id of the ping awaiting its answer, empty when none
+	pingSent time.Time // Send time of that ping
+}
+
+func (h *health) received(now time.Time) { h.lastInbound.Store(now.UnixNano()) }
+
+// awaitPong records the ping about to be sent, replacing the one still awaited.
+func (h *health) awaitPong(now time.Time, id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.pingID, h.pingSent = id, now
+}
+
+// ponged records the round trip when id answers the awaited ping. A pong without
+// req_id answers the awaited ping, as only one is outstanding at a time.
+func (h *health) ponged(now time.Time, id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.pingID == "" || (id != "" && id != h.pingID) {
+		return
+	}
+	h.rtt.Store(now.Sub(h.pingSent).Nanoseconds())
+	h.pingID = ""
+}
+
+// forgetPing stops awaiting the answer of id, or of any ping when id is empty.
+func (h *health) forgetPing(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if id == "" || id == h.pingID {
+		h.pingID = ""
+	}
+}
+
+// Health returns when the last frame was received and the ping round trip.
+func (ln *listener) Health() Health {
+	return Health{
+		LastActivity: unixNano(ln.health.lastInbound.Load()),
+		LastPing:     unixNano(ln.health.lastPing.Load()),
+		RTT:          time.Duration(ln.health.rtt.Load()),
+	}
+}
+
+// checkAlive closes the connection when nothing was received for idlePings
+// ping intervals, and reports whether it is still considered alive.
+func (ln *listener) checkAlive(interval time.Duration) bool {
+	if ln.idlePings <= 0 {
+		return true
+	}
+
+	idle := time.Since(unixNano(ln.health.lastInbound.Load()))
+	if idle < time.Duration(ln.idlePings)*interval {
+		return true
+	}
+
+	client := ln.getClient()
+	if client == nil {
+		return false
+	}
+
+	logger.Log(ln.sc).Warnf("Nothing received for %s, reconnecting", idle.Round(time.Millisecond))
+	ln.health.forgetPing("")
+	go client.Close(closeDeadConnection, fmt.Sprintf("no activity for %s", idle.Round(time.Millisecond)))
+	return false
+}
+
+// sendPing sends a ping carrying a req_id. The pong echoing it, or the next pong
+// without req_id, gives the RTT.
+func (ln *listener) sendPing(ctx context.Context) error {
+	now := time.Now()
+	p := WSPayload{
+		Version: 1,
+		CMD:     2,
+		SubCMD:  1,
+		Data:    map[string]any{"eventId": now.UnixMilli()},
+	}
+	id := ln.addRequestID(&p)
+
+	// Awaited before writing, as the pong may be read before Write returns
+	ln.health.awaitPong(now, id)
+	if err := ln.SendWS(ctx, p, false); err != nil {
+		ln.health.forgetPing(id)
+		return err
+	}
+	ln.health.lastPing.Store(now.UnixNano())
+	return nil
+}
+
+func unixNano(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
@@ -0,0 +1,136 @@
+package listener
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"iter"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Amrakk/zcago/errs"
+	"github.com/Amrakk/zcago/listener/events"
+	"github.com/Amrakk/zcago/model"
+)
+
+type HistoryOptions struct {
+	Limit int       // Maximum number of messages to yield, 0 for no limit
+	Since time.Time // Stop at the first message sent before this time, zero for no limit
+}
+
+// History iterates over old messages of the given thread type, newest first,
+// starting before the message ID before (nil for the most recent ones). Pages are
+// requested on demand, so breaking out of the loop stops the pagination.
+//
+// The replies answering History are not sent to the OldMessages channel.
+// Messages without a valid timestamp are skipped. An error is yielded once and
+// ends the iteration.
+func (ln *listener) History(ctx context.Context, tt model.ThreadType, before *string, opts HistoryOptions) iter.Seq2[model.Message, error] {
+	return func(yield func(model.Message, error) bool) {
+		lastID := before
+		seen := make(map[string]struct{})
+		count := 0
+
+		for {
+			page, err := ln.requestHistoryPage(ctx, tt, lastID)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			fresh := 0
+			for _, msg := range page {
+				if _, ok := seen[msg.MsgID]; ok {
+					continue
+				}
+				seen[msg.MsgID] = struct{}{}
+				fresh++
+
+				ts, ok := messageTime(msg)
+				if !ok {
+					continue
+				}
+				if !opts.Since.IsZero() && ts.Before(opts.Since) {
+					return
+				}
+				if !yield(ln.newHistoryMessage(tt, msg), nil) {
+					return
+				}
+
+				count++
+				if opts.Limit > 0 && count >= opts.Limit {
+					return
+				}
+			}
+
+			if fresh == 0 {
+				return
+			}
+			oldest := page[len(page)-1].MsgID
+			lastID = &oldest
+		}
+	}
+}
+
+// requestHistoryPage fetches the page of messages preceding lastID, sorted newest first.
+func (ln *listener) requestHistoryPage(ctx context.Context, tt model.ThreadType, lastID *string) ([]model.TMessage, error) {
+	cmd := uint16(510)
+	if tt == model.ThreadTypeGroup {
+		cmd = 511
+	}
+
+	data, err := ln.Request(ctx, WSPayload{
+		Version: 1,
+		CMD:     cmd,
+		SubCMD:  1,
+		Data: map[string]any{
+			"first":  true,
+			"lastId": lastID,
+			"preIds": []string{},
+		},
+	})
+	if err != nil {
+		return nil, errs.WrapZCA("failed to request old messages", "listener.History", err)
+	}
+
+	var eventData events.OldMessagesEventData
+	if err := json.Unmarshal(data, &eventData); err != nil {
+		return nil, errs.WrapZCA("failed to decode event data", "listener.History", err)
+	}
+
+	page := eventData.Msgs
+	if tt == model.ThreadTypeGroup {
+		page = eventData.GroupMsgs
+	}
+	slices.SortStableFunc(page, func(a, b model.TMessage) int {
+		return compareMsgID(b.MsgID, a.MsgID)
+	})
+
+	return page, nil
+}
+
+func (ln *listener) newHistoryMessage(tt model.ThreadType, msg model.TMessage) model.Message {
+	if tt == model.ThreadTypeGroup {
+		return model.NewGroupMessage(ln.sc.UID(), model.TGroupMessage{TMessage: msg})
+	}
+	return model.NewUserMessage(ln.sc.UID(), msg)
+}
+
+func messageTime(msg model.TMessage) (time.Time, bool) {
+	ms, err := strconv.ParseInt(msg.TS, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(ms), true
+}
+
+// compareMsgID orders numeric message IDs, which grow with time.
+func compareMsgID(a, b string) int {
+	x, errA := strconv.ParseInt(a, 10, 64)
+	y, errB := strconv.ParseInt(b, 10, 64)
+	if errA != nil || errB != nil {
+		return cmp.Or(cmp.Compare(len(a), len(b)), cmp.Compare(a, b))
+	}
+	return cmp.Compare(x, y)
+}
@@ -2,12 +2,13 @@ package listener
 
 import (
 	"context"
-	"net/http"
+	"encoding/json"
+	"errors"
+	"iter"
 	"net/url"
 	"sync"
 	"time"
 
-	"github.com/Amrakk/zcago/config"
 	"github.com/Amrakk/zcago/errs"
 	"github.com/Amrakk/zcago/internal/httpx"
 	"github.com/Amrakk/zcago/internal/websocketx"
@@ -28,6 +29,22 @@ type Listener interface {
 	Start(ctx context.Context, retryOnClose bool) error
 	Stop()
 
+	// Configure applies options before Start, see WithBuffers and WithBackpressure.
+	Configure(opts ...Option) error
+	// DroppedEvents returns the number of events dropped on each channel so far.
+	DroppedEvents() map[string]uint64
+
+	// Subscribe fans events matching filter out to a new channel, see WithSubscriberBuffer.
+	Subscribe(filter EventFilter, opts ...SubscribeOption) (<-chan Event, func())
+	// SubscribeThread subscribes to the events of a single thread.
+	SubscribeThread(threadID string, threadType model.ThreadType, opts ...SubscribeOption) (<-chan Event, func())
+
+	// State returns the connection state, SubscribeState subscribes to its changes.
+	State() StateInfo
+	SubscribeState(opts ...SubscribeOption) (<-chan Event, func())
+	// Health returns the time of the last frame received and the ping round trip.
+	Health() Health
+
 	// Channels
 	Connected() <-chan struct{}
 	Disconnected() <-chan websocketx.CloseInfo
@@ -46,18 +63,38 @@ type Listener interface {
 	Friend() <-chan model.FriendEvent
 	Group() <-chan model.GroupEvent
 	CipherKey() <-chan string
+	// Raw receives every frame with its decrypted data once enabled by WithRawFrames.
+	Raw() <-chan RawFrame
+
+	// HandleRaw registers a handler for the frames of a router key, which may
+	// take over their built-in routing. Call remove to unregister it.
+	HandleRaw(key string, h RawHandler) (remove func())
 
 	SendWS(ctx context.Context, payload WSPayload, requireID bool) error
+	// Request sends payload and waits for the reply matching its req_id, see DefaultRequestTimeout.
+	Request(ctx context.Context, payload WSPayload) (json.RawMessage, error)
 
 	RequestOldMessages(ctx context.Context, threadType model.ThreadType, lastMsgID *string) error
 	RequestOldReactions(ctx context.Context, threadType model.ThreadType, lastMsgID *string) error
+
+	// History iterates over old messages, newest first, paging backwards until opts or the caller stop it.
+	History(ctx context.Context, threadType model.ThreadType, before *string, opts HistoryOptions) iter.Seq2[model.Message, error]
 }
 
 type listener struct {
 	mu sync.RWMutex
 
-	ch    channels
-	reqID uint64
+	ch          *channels
+	reqID       uint64
+	pending     map[string]*pendingRequest
+	requestKeys map[string]chan struct{} // Holds a token while a request is in flight on the key
+	subs        subscribers
+
+	rawHandlers map[string][]*rawHandler
+	recovery    recoveryState
+	state       connState
+	health      health
+	idlePings   int
 
 	client websocketx.Client
 	sc     session.MutableContext
@@ -69,6 +106,12 @@ type listener struct {
 	retryStates map[string]*retryState
 	rotateCount int
 
+	fallback     FallbackOptions
+	transport    Transport
+	dialFailures int  // Consecutive websocket dial failures
+	starting     bool // Set while Start dials, without holding mu
+	blockReads   bool // Set when a channel uses BackpressureBlock, the connection then waits for the reader too
+
 	cipherKey string
 
 	selfListen  bool
@@ -80,7 +123,7 @@ type listener struct {
 
 var _ Listener = (*listener)(nil)
 
-func New(sc session.MutableContext, urls []string) (*listener, error) {
+func New(sc session.MutableContext, urls []string, opts ...Option) (*listener, error) {
 	if err := validateInputs(sc, urls); err != nil {
 		return nil, err
 	}
@@ -91,12 +134,10 @@ func New(sc session.MutableContext, urls []string) (*listener, error) {
 	}
 
 	retryStates := buildRetryStates(sc)
-	channels := initializeChannels()
 
-	return &listener{
+	ln := &listener{
 		sc: sc,
 
-		ch:    channels,
 		reqID: 0,
 
 		urls:      urls,
@@ -109,61 +150,93 @@ func New(sc session.MutableContext, urls []string) (*listener, error) {
 		cipherKey:   "",
 		selfListen:  sc.Options().SelfListen,
 		pingStopper: nil,
-	}, nil
+	}
+	ln.state.info = StateInfo{State: StateIdle, Since: time.Now()}
+
+	if err := ln.applyOptions(buildOptions(opts)); err != nil {
+		return nil, err
+	}
+
+	return ln, nil
 }
 
 func (ln *listener) Start(ctx context.Context, retryOnClose bool) error {
 	ln.mu.Lock()
-	defer ln.mu.Unlock()
 
-	if ln.client != nil {
+	if ln.client != nil || ln.starting {
+		ln.mu.Unlock()
 		return errs.NewZCA("Already started", "listener.Start")
 	}
 	if ctx.Err() != nil {
+		ln.mu.Unlock()
 		err := ctx.Err()
 		return errs.WrapZCA("context cancelled", "listener.Start", err)
 	}
 
 	lctx, cancel := context.WithCancel(ctx)
 	ln.cancel = cancel
+	ln.starting = true
+	dialing := ln.stateLocked(StateInfo{State: StateDialing})
+	ln.mu.Unlock()
 
-	client, err := ln.createWebSocketConnection(lctx)
+	ln.publish(ctx, KindState, dialing)
+
+	// Dialing may retry with backoff, so it runs without holding ln.mu
+	client, transport, err := ln.dial(lctx)
+
+	ln.mu.Lock()
+	ln.starting = false
+	if err == nil && lctx.Err() != nil {
+		// Stopped while dialing
+		client.Close(ZaloManualClosure, "")
+		err = errs.WrapZCA("context cancelled", "listener.Start", lctx.Err())
+	}
 	if err != nil {
 		cancel()
+		idle := ln.stateLocked(StateInfo{State: StateIdle})
+		ln.mu.Unlock()
+
+		ln.publish(ctx, KindState, idle)
 		return err
 	}
 
 	ln.client = client
+	ln.health.received(time.Now())
+	ln.health.forgetPing("")
+	connected := ln.stateLocked(StateInfo{State: StateConnected})
+	probe := ln.shouldProbeWebSocket(transport)
+	ln.wg.Add(1)
+	if probe {
+		ln.wg.Add(1)
+	}
+	ln.mu.Unlock()
 
+	// Published before run starts so that they precede the events of the connection
+	ln.publish(lctx, KindState, connected)
+	ln.publish(lctx, KindConnected, struct{}{})
 	select {
 	case ln.ch.Connected <- struct{}{}:
 	default:
 	}
 
-	ln.wg.Add(1)
 	go ln.run(lctx, retryOnClose)
+	if probe {
+		go ln.probeWebSocket(lctx, client)
+	}
 
 	return nil
 }
 
-func (ln *listener) createWebSocketConnection(ctx context.Context) (websocketx.Client, error) {
-	u, err := url.Parse(ln.wsURL)
+func (ln *listener) createWebSocketConnection(ctx context.Context, wsURL string) (websocketx.Client, error) {
+	u, err := url.Parse(wsURL)
 	if err != nil {
 		return nil, errs.WrapZCA("parse websocket URL failed", "listener.createWebSocketConnection", err)
 	}
 
-	h := make(http.Header)
-	h.Set("Accept-Encoding", "gzip, deflate, br, zstd")
-	h.Set("Accept-Language", "en-US,en;q=0.9")
-	h.Set("Cache-Control", "no-cache")
-	h.Set("Host", u.Host)
-	h.Set("Origin", config.DefaultURL.String())
-	h.Set("Pragma", "no-cache")
-	h.Set("User-Agent", ln.userAgent)
-
-	client, err := websocketx.Dial(ctx, ln.wsURL, &websocketx.Options{
-		Header:     h,
-		HTTPClient: ln.sc.Client(),
+	client, err := websocketx.Dial(ctx, wsURL, &websocketx.Options{
+		Header:      ln.connectionHeader(u),
+		HTTPClient:  ln.sc.Client(),
+		BlockOnFull: ln.blockReads,
 	})
 	if err != nil {
 		return nil, errs.WrapZCA("websocket dial failed", "listener.createWebSocketConnection", err)
@@ -221,6 +294,7 @@ func (ln *listener) run(ctx context.Context, retryOnClose bool) {
 func (ln *listener) handleConnectionClose(ctx context.Context, ci websocketx.CloseInfo, retryOnClose bool) {
 	ln.reset()
 
+	ln.publish(ctx, KindDisconnected, ci)
 	select {
 	case ln.ch.Disconnected <- ci:
 	case <-ctx.Done():
@@ -229,6 +303,13 @@ func (ln *listener) handleConnectionClose(ctx context.Context, ci websocketx.Clo
 	}
 
 	if delay, ok := ln.shouldRetryConnection(ctx, ci, retryOnClose); ok {
+		ln.recovery.schedule()
+		ln.setState(ctx, StateInfo{
+			State:   StateReconnecting,
+			Attempt: ln.retryAttempt(ci.Code),
+			Delay:   time.Duration(delay) * time.Millisecond,
+			Reason:  ci,
+		})
 		if err := ln.scheduleReconnection(ctx, ci, delay); err != nil {
 			ln.emitError(ctx, errs.WrapZCA("failed to schedule reconnection:", "listener.handleConnectionClose", err))
 			ln.emitClosed(ctx, ci)
@@ -244,6 +325,8 @@ func (ln *listener) handleConnectionClose(ctx context.Context, ci websocketx.Clo
 func (ln *listener) Stop() {
 	client := ln.getClient()
 	if client == nil {
+		ln.cancelStarting()
+		ln.ch.closeSpills()
 		return
 	}
 
@@ -251,6 +334,8 @@ func (ln *listener) Stop() {
 	client.Close(ZaloManualClosure, "")
 
 	ln.wg.Wait()
+	ln.ch.closeSpills()
+	ln.setState(context.Background(), StateInfo{State: StateClosed, Reason: CloseInfo{Code: ZaloManualClosure}})
 }
 
 func (ln *listener) reset() {
@@ -267,9 +352,19 @@ func (ln *listener) reset() {
 	}
 
 	ln.reqID = 0
+	ln.abortRequestsLocked()
 	ln.cipherKey = ""
 }
 
+// cancelStarting makes a Start still dialing give up.
+func (ln *listener) cancelStarting() {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+	if ln.starting && ln.cancel != nil {
+		ln.cancel()
+	}
+}
+
 func (ln *listener) cancelActiveContext() {
 	ln.mu.Lock()
 	defer ln.mu.Unlock()
@@ -335,27 +430,56 @@ func buildRetryStates(sc session.MutableContext) map[string]*retryState {
 			}
 		}
 	}
+	for reason, st := range listenerRetryStates() {
+		if _, ok := retryStates[reason]; !ok {
+			retryStates[reason] = st
+		}
+	}
 	return retryStates
 }
 
-func initializeChannels() channels {
-	buf := defaultBuffers()
-	return channels{
-		Connected:         make(chan struct{}, buf.Connected),
-		Disconnected:      make(chan websocketx.CloseInfo, buf.Disconnected),
-		Closed:            make(chan websocketx.CloseInfo, buf.Closed),
-		Error:             make(chan error, buf.Error),
-		Message:           make(chan model.Message, buf.Message),
-		OldMessages:       make(chan model.OldMessages, buf.OldMessages),
-		Reaction:          make(chan model.Reaction, buf.Reaction),
-		OldReactions:      make(chan model.OldReactions, buf.OldReactions),
-		Typing:            make(chan model.Typing, buf.Typing),
-		DeliveredMessages: make(chan []model.DeliveredMessage, buf.DeliveredMessages),
-		SeenMessages:      make(chan []model.SeenMessage, buf.SeenMessages),
-		Undo:              make(chan model.Undo, buf.Undo),
-		UploadAttachment:  make(chan model.UploadAttachment, buf.UploadAttachment),
-		Friend:            make(chan model.FriendEvent, buf.Friend),
-		Group:             make(chan model.GroupEvent, buf.Group),
-		CipherKey:         make(chan string, buf.CipherKey),
+func (ln *listener) initializeChannels(cfg options) (*channels, error) {
+	buf, pol, dir, only := cfg.buffers, cfg.policies, cfg.spillDir, cfg.subscribeOnly
+	ch := &channels{
+		Connected:    make(chan struct{}, buf.Connected),
+		Disconnected: make(chan websocketx.CloseInfo, buf.Disconnected),
+		Closed:       make(chan websocketx.CloseInfo, buf.Closed),
+		Error:        make(chan error, buf.Error),
+		CipherKey:    make(chan string, buf.CipherKey),
+		rawFrames:    cfg.raw,
 	}
+
+	uid := ln.sc.UID
+	err := errors.Join(
+		attachOutlet(ln, &ch.Message, "Message", KindMessage, buf.Message, pol.Message, messageCodec(uid), dir, only),
+		attachOutlet(ln, &ch.OldMessages, "OldMessages", KindOldMessages, buf.OldMessages, pol.OldMessages, oldMessagesCodec(uid), dir, only),
+		attachOutlet(ln, &ch.Reaction, "Reaction", KindReaction, buf.Reaction, pol.Reaction, jsonCodec[model.Reaction](), dir, only),
+		attachOutlet(ln, &ch.OldReactions, "OldReactions", KindOldReactions, buf.OldReactions, pol.OldReactions, jsonCodec[model.OldReactions](), dir, only),
+		attachOutlet(ln, &ch.Typing, "Typing", KindTyping, buf.Typing, pol.Typing, nil, dir, only),
+		attachOutlet(ln, &ch.DeliveredMessages, "DeliveredMessages", KindDeliveredMessages, buf.DeliveredMessages, pol.DeliveredMessages, nil, dir, only),
+		attachOutlet(ln, &ch.SeenMessages, "SeenMessages", KindSeenMessages, buf.SeenMessages, pol.SeenMessages, nil, dir, only),
+		attachOutlet(ln, &ch.Undo, "Undo", KindUndo, buf.Undo, pol.Undo, jsonCodec[model.Undo](), dir, only),
+		attachOutlet(ln, &ch.UploadAttachment, "UploadAttachment", KindUploadAttachment, buf.UploadAttachment, pol.UploadAttachment, jsonCodec[model.UploadAttachment](), dir, only),
+		attachOutlet(ln, &ch.Friend, "Friend", KindFriend, buf.Friend, pol.Friend, nil, dir, only),
+		attachOutlet(ln, &ch.Group, "Group", KindGroup, buf.Group, pol.Group, nil, dir, only),
+		attachOutlet(ln, &ch.Raw, "Raw", KindRaw, buf.Raw, pol.Raw, nil, dir, only),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ch, nil
+}
+
+func attachOutlet[T any](ln *listener, dst **outlet[T], name string, kind EventKind, size int, policy BackpressurePolicy, codec *spillCodec[T], spillDir string, publishOnly bool) error {
+	o, err := newOutlet(name, size, policy, codec, spillDir)
+	if err != nil {
+		return err
+	}
+	o.report = ln.emitError
+	o.kind = kind
+	o.publish = ln.publish
+	o.publishOnly = publishOnly
+	*dst = o
+	return nil
 }
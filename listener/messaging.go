@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/Amrakk/zcago/errs"
 	"github.com/Amrakk/zcago/internal/websocketx"
@@ -96,15 +97,21 @@ func (ln *listener) validateSendRequest(ctx context.Context) error {
 	return nil
 }
 
-func (ln *listener) addRequestID(p *WSPayload) {
+func (ln *listener) addRequestID(p *WSPayload) string {
 	ln.mu.Lock()
 	defer ln.mu.Unlock()
 
+	return ln.addRequestIDLocked(p)
+}
+
+func (ln *listener) addRequestIDLocked(p *WSPayload) string {
 	if p.Data == nil {
 		p.Data = map[string]any{}
 	}
-	p.Data["req_id"] = "req_" + fmt.Sprint(ln.reqID)
+	id := "req_" + fmt.Sprint(ln.reqID)
+	p.Data["req_id"] = id
 	ln.reqID++
+	return id
 }
 
 // ----------------------------------------
@@ -112,6 +119,7 @@ func (ln *listener) addRequestID(p *WSPayload) {
 // ----------------------------------------
 
 type WSMessage[T any] struct {
+	ReqID        string  `json:"req_id,omitempty"`
 	Key          *string `json:"key"`
 	Encrypt      uint    `json:"encrypt"`
 	ErrorCode    int     `json:"error_code"`
@@ -122,6 +130,8 @@ type WSMessage[T any] struct {
 type BaseWSMessage = WSMessage[string]
 
 func (ln *listener) handleWebSocketMessage(ctx context.Context, msg websocketx.Message) {
+	ln.health.received(time.Now())
+
 	if msg.Type != websocketx.BinaryMessage {
 		return
 	}
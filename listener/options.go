@@ -0,0 +1,154 @@
+package listener
+
+import (
+	"os"
+	"time"
+
+	"github.com/Amrakk/zcago/errs"
+)
+
+type Option func(*options)
+
+type options struct {
+	buffers  Buffers
+	policies Policies
+	spillDir string
+	raw      bool
+	recover  bool
+
+	subscribeOnly bool
+
+	dedupe    bool
+	dedupeMax int
+	dedupeTTL time.Duration
+
+	fallback  FallbackOptions
+	idlePings int
+}
+
+// WithBuffers sets the buffer size of each channel. Fields left at zero keep
+// their DefaultBuffers size.
+func WithBuffers(b Buffers) Option { return func(o *options) { o.buffers = b } }
+
+// WithBackpressure sets what each event channel does with new events when full.
+func WithBackpressure(p Policies) Option { return func(o *options) { o.policies = p } }
+
+// WithSpillDir sets the directory of the BackpressureSpillToDisk overflow files,
+// os.TempDir by default. Spilled events keep draining across reconnections, the
+// files are deleted by Stop along with the events not delivered yet.
+func WithSpillDir(dir string) Option { return func(o *options) { o.spillDir = dir } }
+
+// WithRawFrames enables the Raw channel, which receives every frame read from
+// the websocket, including the ones the listener doesn't know about.
+func WithRawFrames(enabled bool) Option { return func(o *options) { o.raw = enabled } }
+
+// WithRecovery sets whether the messages and reactions missed while the
+// connection was down are fetched after reconnecting, disabled by default.
+// Live messages, reactions and undos are held back until the recovered ones
+// are emitted.
+func WithRecovery(enabled bool) Option { return func(o *options) { o.recover = enabled } }
+
+// WithDedupe drops the messages, reactions, undos and delivered messages
+// already emitted, as Zalo may send them more than once and reconnects may
+// replay them. Up to size event keys are remembered, DefaultDedupeSize when
+// size is 0, and forgotten after ttl unless it is 0.
+func WithDedupe(size int, ttl time.Duration) Option {
+	return func(o *options) {
+		o.dedupe = true
+		o.dedupeMax = size
+		o.dedupeTTL = ttl
+	}
+}
+
+// WithFallback configures the long-polling fallback used when the websocket
+// can't be dialed, see FallbackOptions. The fallback is experimental and off
+// unless FallbackOptions.Enabled is set.
+func WithFallback(o FallbackOptions) Option { return func(opts *options) { opts.fallback = o } }
+
+// WithIdleTimeout forces a reconnect when nothing is received for the given
+// number of ping intervals, 3 by default. 0 disables the check.
+func WithIdleTimeout(pings int) Option { return func(o *options) { o.idlePings = pings } }
+
+// WithSubscribeOnly stops feeding the per-kind event channels such as Message and
+// Reaction, for listeners consumed through Subscribe only. Events nobody reads
+// then don't fill those channels and report drops on the Error channel.
+func WithSubscribeOnly(enabled bool) Option { return func(o *options) { o.subscribeOnly = enabled } }
+
+func defaultOptions() options {
+	return options{
+		buffers:   DefaultBuffers(),
+		spillDir:  os.TempDir(),
+		idlePings: 3,
+	}
+}
+
+func buildOptions(opts []Option) options {
+	cfg := defaultOptions()
+	for _, fn := range opts {
+		if fn != nil {
+			fn(&cfg)
+		}
+	}
+	cfg.buffers = mergeBuffers(cfg.buffers, DefaultBuffers())
+	cfg.fallback = mergeFallbackOptions(cfg.fallback)
+	return cfg
+}
+
+// Configure applies opts to a listener that has not been started yet. It
+// replaces every channel, so channels obtained before calling it are stale.
+func (ln *listener) Configure(opts ...Option) error {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+
+	if ln.client != nil {
+		return errs.NewZCA("cannot configure a started listener", "listener.Configure")
+	}
+
+	return ln.applyOptions(buildOptions(opts))
+}
+
+func (ln *listener) applyOptions(cfg options) error {
+	ch, err := ln.initializeChannels(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.dedupe {
+		attachDedupe(ch, newDedupeCache(cfg.dedupeMax, cfg.dedupeTTL))
+	}
+
+	ln.ch = ch
+	ln.recovery.enabled = cfg.recover
+	ln.fallback = cfg.fallback
+	ln.idlePings = cfg.idlePings
+	ln.blockReads = cfg.policies.blocks()
+	return nil
+}
+
+func mergeBuffers(b, def Buffers) Buffers {
+	pick := func(v, d int) int {
+		if v > 0 {
+			return v
+		}
+		return d
+	}
+
+	return Buffers{
+		Connected:         pick(b.Connected, def.Connected),
+		Disconnected:      pick(b.Disconnected, def.Disconnected),
+		Closed:            pick(b.Closed, def.Closed),
+		Error:             pick(b.Error, def.Error),
+		Message:           pick(b.Message, def.Message),
+		OldMessages:       pick(b.OldMessages, def.OldMessages),
+		Typing:            pick(b.Typing, def.Typing),
+		SeenMessages:      pick(b.SeenMessages, def.SeenMessages),
+		DeliveredMessages: pick(b.DeliveredMessages, def.DeliveredMessages),
+		Reaction:          pick(b.Reaction, def.Reaction),
+		OldReactions:      pick(b.OldReactions, def.OldReactions),
+		Undo:              pick(b.Undo, def.Undo),
+		UploadAttachment:  pick(b.UploadAttachment, def.UploadAttachment),
+		Friend:            pick(b.Friend, def.Friend),
+		Group:             pick(b.Group, def.Group),
+		CipherKey:         pick(b.CipherKey, def.CipherKey),
+		Raw:               pick(b.Raw, def.Raw),
+	}
+}
@@ -0,0 +1,105 @@
+package listener
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/Amrakk/zcago/errs"
+)
+
+// RawFrame is a websocket frame as received, before the built-in routing.
+type RawFrame struct {
+	Version uint
+	CMD     uint
+	SubCMD  uint
+	Key     string // Router key, "<version>_<cmd>_<subCmd>" such as "1_501_0"
+
+	Frame BaseWSMessage   // Envelope of the frame, with its data still encrypted
+	Data  json.RawMessage // Decrypted JSON of the data, nil when the frame has none
+	Err   error           // Set when the data could not be decrypted
+}
+
+// RawHandler handles the frames of a router key. Returning true stops the
+// built-in routing of the frame, so the typed channels don't receive it.
+type RawHandler func(ctx context.Context, frame RawFrame) bool
+
+type rawHandler struct {
+	fn RawHandler
+}
+
+// HandleRaw registers h for the frames of key, e.g. "1_501_0" for user
+// messages. Handlers run in registration order on the reading goroutine, ahead
+// of the built-in routing, and receive frames whether or not the key is known
+// to the listener. remove unregisters h.
+func (ln *listener) HandleRaw(key string, h RawHandler) (remove func()) {
+	entry := &rawHandler{fn: h}
+
+	ln.mu.Lock()
+	if ln.rawHandlers == nil {
+		ln.rawHandlers = make(map[string][]*rawHandler)
+	}
+	ln.rawHandlers[key] = append(ln.rawHandlers[key], entry)
+	ln.mu.Unlock()
+
+	return func() {
+		ln.mu.Lock()
+		defer ln.mu.Unlock()
+
+		handlers := slices.DeleteFunc(ln.rawHandlers[key], func(e *rawHandler) bool { return e == entry })
+		if len(handlers) == 0 {
+			delete(ln.rawHandlers, key)
+			return
+		}
+		ln.rawHandlers[key] = handlers
+	}
+}
+
+func (ln *listener) Raw() <-chan RawFrame { return ln.ch.Raw.ch }
+
+// handleRaw emits the frame on the Raw channel when enabled and runs the
+// handlers registered for its key. It reports whether a handler took over the
+// routing of the frame.
+func (ln *listener) handleRaw(ctx context.Context, version, cmd, sub uint, key string, body BaseWSMessage) bool {
+	ln.mu.RLock()
+	handlers := slices.Clone(ln.rawHandlers[key])
+	ln.mu.RUnlock()
+
+	if !ln.ch.rawFrames && len(handlers) == 0 {
+		return false
+	}
+
+	frame := RawFrame{Version: version, CMD: cmd, SubCMD: sub, Key: key, Frame: body}
+	if body.Data != "" {
+		payload, err := decodeEventPayload(body, ln.getCipherKey())
+		if err != nil {
+			frame.Err = err
+		} else {
+			frame.Data = payload
+		}
+	}
+
+	if ln.ch.rawFrames {
+		emit(ctx, ln.ch.Raw, frame)
+	}
+
+	handled := false
+	for _, h := range handlers {
+		if ln.callRawHandler(ctx, h.fn, frame) {
+			handled = true
+		}
+	}
+	return handled
+}
+
+// callRawHandler keeps a panicking handler from stopping the listener.
+func (ln *listener) callRawHandler(ctx context.Context, h RawHandler, frame RawFrame) (handled bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ln.emitError(ctx, errs.NewZCA(fmt.Sprintf("raw handler for %s panicked: %v", frame.Key, r), "listener.HandleRaw"))
+			handled = false
+		}
+	}()
+	return h(ctx, frame)
+}
@@ -0,0 +1,308 @@
+package listener
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/Amrakk/zcago/errs"
+	"github.com/Amrakk/zcago/listener/events"
+	"github.com/Amrakk/zcago/model"
+)
+
+// RecoveryLimit bounds the number of messages, and of reactions, fetched per
+// thread type after a reconnect.
+const RecoveryLimit = 500
+
+type recoveryKind uint8
+
+const (
+	recoveryMessage recoveryKind = iota
+	recoveryReaction
+)
+
+type recoveryKey struct {
+	kind recoveryKind
+	tt   model.ThreadType
+	id   string
+}
+
+// recoveryState remembers the newest message and reaction IDs emitted per
+// thread type, so that what arrived while disconnected can be fetched back.
+type recoveryState struct {
+	mu      sync.Mutex
+	enabled bool
+
+	last  map[recoveryKey]string // Newest ID per kind and thread type, id is empty in the keys
+	since map[recoveryKey]string // last when the connection dropped, set while a recovery is pending
+	live  map[recoveryKey]struct{}
+
+	holding bool     // Set while a recovery runs, live events then wait in held
+	held    []func() // Emission of the live events received meanwhile, in order
+}
+
+// mark records the ID of an event emitted live.
+func (rs *recoveryState) mark(kind recoveryKind, tt model.ThreadType, id string) {
+	if id == "" {
+		return
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if !rs.enabled {
+		return
+	}
+	if rs.last == nil {
+		rs.last = make(map[recoveryKey]string)
+	}
+	k := recoveryKey{kind: kind, tt: tt}
+	if compareMsgID(id, rs.last[k]) > 0 {
+		rs.last[k] = id
+	}
+
+	// Events received between the reconnect and the end of the recovery may be
+	// fetched again, they are remembered to skip them
+	if rs.live != nil && len(rs.live) < RecoveryLimit*4 {
+		rs.live[recoveryKey{kind: kind, tt: tt, id: id}] = struct{}{}
+	}
+}
+
+// schedule arms the recovery for the next connection.
+func (rs *recoveryState) schedule() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if !rs.enabled || len(rs.last) == 0 {
+		return
+	}
+	// A recovery still pending keeps the older starting point
+	if rs.since == nil {
+		rs.since = maps.Clone(rs.last)
+	}
+	rs.live = make(map[recoveryKey]struct{})
+}
+
+// begin returns the IDs to recover from, or false when no recovery is pending.
+// Live events are held from then on until end.
+func (rs *recoveryState) begin() (map[recoveryKey]string, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	since := rs.since
+	rs.since = nil
+	if since != nil {
+		rs.holding = true
+	}
+	return since, since != nil
+}
+
+// hold queues the emission of a live event while a recovery runs, and reports
+// whether it did.
+func (rs *recoveryState) hold(emit func()) bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if !rs.holding {
+		return false
+	}
+	rs.held = append(rs.held, emit)
+	return true
+}
+
+// claim reports whether a recovered event should be emitted, i.e. it was not
+// received live meanwhile, and records it.
+func (rs *recoveryState) claim(kind recoveryKind, tt model.ThreadType, id string) bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	k := recoveryKey{kind: kind, tt: tt, id: id}
+	if _, ok := rs.live[k]; ok {
+		return false
+	}
+	if rs.live != nil {
+		rs.live[k] = struct{}{}
+	}
+
+	last := recoveryKey{kind: kind, tt: tt}
+	if compareMsgID(id, rs.last[last]) > 0 {
+		rs.last[last] = id
+	}
+	return true
+}
+
+// end emits the live events held during the recovery, including the ones
+// arriving meanwhile, then lets the next ones through.
+func (rs *recoveryState) end() {
+	for {
+		rs.mu.Lock()
+		held := rs.held
+		rs.held = nil
+		if len(held) == 0 {
+			rs.holding = false
+			if rs.since == nil {
+				rs.live = nil
+			}
+			rs.mu.Unlock()
+			return
+		}
+		rs.mu.Unlock()
+
+		for _, emit := range held {
+			emit()
+		}
+	}
+}
+
+// emitLive emits a live event, after the recovered ones when a recovery runs.
+func (ln *listener) emitLive(emit func()) {
+	if !ln.recovery.hold(emit) {
+		emit()
+	}
+}
+
+// recoverMissed fetches the messages and reactions sent while the connection
+// was down and emits them oldest first, with their Recovered flag set, since
+// being the result of begin. The live events held meanwhile follow.
+func (ln *listener) recoverMissed(ctx context.Context, since map[recoveryKey]string) {
+	defer ln.recovery.end()
+
+	uid := ln.sc.UID()
+	for _, tt := range []model.ThreadType{model.ThreadTypeUser, model.ThreadTypeGroup} {
+		if last, ok := since[recoveryKey{kind: recoveryMessage, tt: tt}]; ok {
+			msgs, err := fetchSince(ctx, last, func(ctx context.Context, lastID *string) ([]model.TMessage, error) {
+				return ln.requestHistoryPage(ctx, tt, lastID)
+			}, func(m model.TMessage) string { return m.MsgID })
+			if err != nil {
+				ln.emitError(ctx, errs.WrapZCA("failed to recover missed messages", "listener.recoverMissed", err))
+			}
+
+			for _, data := range msgs {
+				if !ln.recovery.claim(recoveryMessage, tt, data.MsgID) {
+					continue
+				}
+				msg := ln.newRecoveredMessage(tt, data)
+				if msg.IsSelf() && !ln.selfListen {
+					continue
+				}
+				emit(ctx, ln.ch.Message, msg)
+			}
+		}
+
+		if last, ok := since[recoveryKey{kind: recoveryReaction, tt: tt}]; ok {
+			reactions, err := fetchSince(ctx, last, func(ctx context.Context, lastID *string) ([]model.TReaction, error) {
+				return ln.requestReactionPage(ctx, tt, lastID)
+			}, func(r model.TReaction) string { return r.MsgID })
+			if err != nil {
+				ln.emitError(ctx, errs.WrapZCA("failed to recover missed reactions", "listener.recoverMissed", err))
+			}
+
+			for _, data := range reactions {
+				if !ln.recovery.claim(recoveryReaction, tt, data.MsgID) {
+					continue
+				}
+				reaction := model.NewReaction(uid, data, tt)
+				if reaction.IsSelf && !ln.selfListen {
+					continue
+				}
+				reaction.Recovered = true
+				emit(ctx, ln.ch.Reaction, reaction)
+			}
+		}
+	}
+}
+
+func (ln *listener) newRecoveredMessage(tt model.ThreadType, data model.TMessage) model.Message {
+	switch msg := ln.newHistoryMessage(tt, data).(type) {
+	case model.GroupMessage:
+		msg.Recovered = true
+		return msg
+	case model.UserMessage:
+		msg.Recovered = true
+		return msg
+	default:
+		return msg
+	}
+}
+
+// fetchSince pages backwards from the most recent items until reaching the ID
+// since, and returns the newer items oldest first. Pages are sorted newest first.
+// What was fetched before an error is returned along with it.
+func fetchSince[T any](ctx context.Context, since string, page func(context.Context, *string) ([]T, error), id func(T) string) ([]T, error) {
+	var (
+		items  []T
+		seen   = make(map[string]struct{})
+		lastID *string
+	)
+
+	for len(items) < RecoveryLimit {
+		batch, err := page(ctx, lastID)
+		if err != nil {
+			slices.Reverse(items)
+			return items, err
+		}
+
+		fresh := 0
+		for _, it := range batch {
+			itemID := id(it)
+			if compareMsgID(itemID, since) <= 0 {
+				slices.Reverse(items)
+				return items, nil
+			}
+			if _, ok := seen[itemID]; ok {
+				continue
+			}
+			seen[itemID] = struct{}{}
+			items = append(items, it)
+			fresh++
+		}
+
+		if fresh == 0 {
+			break
+		}
+		oldest := id(batch[len(batch)-1])
+		lastID = &oldest
+	}
+
+	slices.Reverse(items)
+	return items, nil
+}
+
+// requestReactionPage fetches the page of reactions preceding lastID, sorted newest first.
+func (ln *listener) requestReactionPage(ctx context.Context, tt model.ThreadType, lastID *string) ([]model.TReaction, error) {
+	cmd := uint16(610)
+	if tt == model.ThreadTypeGroup {
+		cmd = 611
+	}
+
+	data, err := ln.Request(ctx, WSPayload{
+		Version: 1,
+		CMD:     cmd,
+		SubCMD:  1,
+		Data: map[string]any{
+			"first":  true,
+			"lastId": lastID,
+			"preIds": []string{},
+		},
+	})
+	if err != nil {
+		return nil, errs.WrapZCA("failed to request old reactions", "listener.recoverMissed", err)
+	}
+
+	var eventData events.ReactionEventData
+	if err := json.Unmarshal(data, &eventData); err != nil {
+		return nil, errs.WrapZCA("failed to decode event data", "listener.recoverMissed", err)
+	}
+
+	page := eventData.Reactions
+	if tt == model.ThreadTypeGroup {
+		page = eventData.GroupReactions
+	}
+	slices.SortStableFunc(page, func(a, b model.TReaction) int {
+		return compareMsgID(b.MsgID, a.MsgID)
+	})
+
+	return page, nil
+}
@@ -0,0 +1,229 @@
+package listener
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Amrakk/zcago/errs"
+	"github.com/Amrakk/zcago/internal/websocketx"
+)
+
+var (
+	ErrRequestAborted = errs.NewZCA("connection closed before the reply was received", "listener.Request")
+	ErrRequestTimeout = errs.NewZCA("timed out waiting for the reply", "listener.Request")
+)
+
+// DefaultRequestTimeout bounds the wait for a reply when the context has no deadline.
+const DefaultRequestTimeout = 15 * time.Second
+
+type pendingRequest struct {
+	key   string // Router key the reply is expected on
+	reply chan BaseWSMessage
+}
+
+// Request sends p with a new req_id and waits for the frame answering it. The
+// reply is consumed here and is not routed to the channels. It returns the
+// decoded data of the reply, or a ZaloAPIError when it carries an error_code.
+//
+// Requests sharing a command run one at a time, as replies not echoing the
+// req_id can only be matched on their command. The wait ends with
+// ErrRequestTimeout after DefaultRequestTimeout unless ctx has its own
+// deadline, and with ErrRequestAborted when the connection closes.
+func (ln *listener) Request(ctx context.Context, p WSPayload) (json.RawMessage, error) {
+	body, err := ln.request(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	if err := replyError(body.ErrorCode, body.ErrorMessage); err != nil {
+		return nil, err
+	}
+
+	decoded, err := decodeEventData[json.RawMessage](body, ln.getCipherKey())
+	if err != nil {
+		return nil, errs.WrapZCA("failed to decode reply", "listener.Request", err)
+	}
+	if err := replyError(decoded.ErrorCode, decoded.ErrorMessage); err != nil {
+		return nil, err
+	}
+
+	return decoded.Data, nil
+}
+
+func replyError(code int, message string) error {
+	if code == 0 {
+		return nil
+	}
+	c := errs.ZaloErrorCode(code)
+	return errs.NewZaloAPIError(message, &c)
+}
+
+func (ln *listener) request(ctx context.Context, p WSPayload) (BaseWSMessage, error) {
+	if err := ln.validateSendRequest(ctx); err != nil {
+		return BaseWSMessage{}, err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultRequestTimeout)
+		defer cancel()
+	}
+
+	key := fmt.Sprintf("%d_%d_%d", p.Version, p.CMD, p.SubCMD)
+	release, err := ln.acquireRequestKey(ctx, key)
+	if err != nil {
+		return BaseWSMessage{}, err
+	}
+	defer release()
+
+	id, pending, client, err := ln.registerRequest(&p, key)
+	if err != nil {
+		return BaseWSMessage{}, err
+	}
+	defer ln.unregisterRequest(id)
+
+	frame, err := encodeFrame(p)
+	if err != nil {
+		return BaseWSMessage{}, errs.WrapZCA("failed to encode frame", "listener.Request", err)
+	}
+	if err := client.Write(ctx, websocketx.BinaryMessage, frame); err != nil {
+		return BaseWSMessage{}, err
+	}
+
+	select {
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return BaseWSMessage{}, ErrRequestTimeout
+		}
+		return BaseWSMessage{}, errs.WrapZCA("context cancelled", "listener.Request", ctx.Err())
+	case body, ok := <-pending.reply:
+		if !ok {
+			return BaseWSMessage{}, ErrRequestAborted
+		}
+		return body, nil
+	}
+}
+
+// acquireRequestKey waits until no other request is in flight on key, the
+// returned function lets the next one go.
+func (ln *listener) acquireRequestKey(ctx context.Context, key string) (func(), error) {
+	ln.mu.Lock()
+	if ln.requestKeys == nil {
+		ln.requestKeys = make(map[string]chan struct{})
+	}
+	sem, ok := ln.requestKeys[key]
+	if !ok {
+		sem = make(chan struct{}, 1)
+		ln.requestKeys[key] = sem
+	}
+	ln.mu.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, ErrRequestTimeout
+		}
+		return nil, errs.WrapZCA("context cancelled", "listener.Request", ctx.Err())
+	}
+}
+
+func (ln *listener) registerRequest(p *WSPayload, key string) (string, *pendingRequest, websocketx.Client, error) {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+
+	if ln.client == nil {
+		return "", nil, nil, errs.NewZCA("listener not started", "listener.Request")
+	}
+
+	id := ln.addRequestIDLocked(p)
+	pending := &pendingRequest{
+		key:   key,
+		reply: make(chan BaseWSMessage, 1),
+	}
+
+	if ln.pending == nil {
+		ln.pending = make(map[string]*pendingRequest)
+	}
+	ln.pending[id] = pending
+
+	return id, pending, ln.client, nil
+}
+
+func (ln *listener) unregisterRequest(id string) {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+	delete(ln.pending, id)
+}
+
+// abortRequestsLocked fails every pending request. Must be called with mu held.
+func (ln *listener) abortRequestsLocked() {
+	for id, pending := range ln.pending {
+		close(pending.reply)
+		delete(ln.pending, id)
+	}
+}
+
+// deliverReply hands body to the pending request it answers and reports whether
+// it did. Replies echoing a req_id are matched on it, others go to the request
+// waiting on the same key, there is at most one per key.
+func (ln *listener) deliverReply(key string, body BaseWSMessage) bool {
+	ln.mu.RLock()
+	waiting := false
+	for _, pending := range ln.pending {
+		if pending.key == key {
+			waiting = true
+			break
+		}
+	}
+	ln.mu.RUnlock()
+	if !waiting {
+		return false
+	}
+
+	id := replyRequestID(body, ln.getCipherKey())
+
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+
+	var target string
+	if id != "" {
+		if pending, ok := ln.pending[id]; ok && pending.key == key {
+			target = id
+		}
+	} else {
+		for pid, pending := range ln.pending {
+			if pending.key == key {
+				target = pid
+				break
+			}
+		}
+	}
+	if target == "" {
+		return false
+	}
+
+	ln.pending[target].reply <- body
+	delete(ln.pending, target)
+	return true
+}
+
+// replyRequestID returns the req_id echoed by a reply, either on the frame
+// itself or inside its decoded data, or "" when there is none.
+func replyRequestID(body BaseWSMessage, cipherKey string) string {
+	if body.ReqID != "" {
+		return body.ReqID
+	}
+
+	decoded, err := decodeEventData[struct {
+		ReqID string `json:"req_id"`
+	}](body, cipherKey)
+	if err != nil {
+		return ""
+	}
+	return cmp.Or(decoded.ReqID, decoded.Data.ReqID)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"slices"
+	"strconv"
 	"time"
 
 	"github.com/Amrakk/zcago/errs"
@@ -18,6 +19,15 @@ type retryState struct {
 	times []int
 }
 
+// listenerRetryStates are the retry budgets of the close codes used by the
+// listener itself, the server settings take precedence when they set them.
+func listenerRetryStates() map[string]*retryState {
+	return map[string]*retryState{
+		strconv.Itoa(closeTransportSwitch): {max: 10, times: []int{1000}},
+		strconv.Itoa(closeDeadConnection):  {max: 10, times: []int{1000, 2000, 5000, 10000, 30000}},
+	}
+}
+
 func (ln *listener) shouldRetryConnection(ctx context.Context, ci websocketx.CloseInfo, retryOnClose bool) (int, bool) {
 	if !retryOnClose || ctx.Err() != nil {
 		return 0, false
@@ -41,10 +51,7 @@ func (ln *listener) scheduleReconnection(ctx context.Context, ci websocketx.Clos
 		}
 
 		if err := ln.Start(ctx, true); err != nil {
-			select {
-			case ln.ch.Closed <- ci:
-			default:
-			}
+			ln.emitClosed(ctx, ci)
 		}
 	})
 
@@ -15,6 +15,16 @@ import (
 func (ln *listener) router(ctx context.Context, version, cmd, sub uint, body BaseWSMessage) {
 	key := fmt.Sprintf("%d_%d_%d", version, cmd, sub)
 
+	if cmd == 2 {
+		ln.health.ponged(time.Now(), replyRequestID(body, ln.getCipherKey()))
+	}
+
+	handled := ln.handleRaw(ctx, version, cmd, sub, key, body)
+
+	if ln.deliverReply(key, body) || handled {
+		return
+	}
+
 	switch key {
 	case "1_1_1":
 		ln.handleCipherKey(ctx, body)
@@ -59,36 +69,41 @@ func (ln *listener) handleCipherKey(ctx context.Context, body BaseWSMessage) {
 		return
 	}
 
-	ln.cipherKey = key
+	ln.setCipherKey(key)
+	ln.setState(ctx, StateInfo{State: StateAuthenticated})
+	ln.publish(ctx, KindCipherKey, key)
 	ln.ch.CipherKey <- key
 
+	// Replies to the recovery requests are read by this goroutine, so it can't wait
+	// for them. Live events are held from now on until the recovery is emitted.
+	if since, ok := ln.recovery.begin(); ok {
+		ln.wg.Go(func() { ln.recoverMissed(ctx, since) })
+	}
+
 	if ln.pingStopper != nil {
 		(*ln.pingStopper)()
 	}
 
+	interval := ln.sc.WSPingInterval()
+	if interval <= 0 {
+		return
+	}
+
 	ping := func() {
-		payload := WSPayload{
-			Version: 1,
-			CMD:     2,
-			SubCMD:  1,
-			Data:    map[string]any{"eventId": time.Now().UnixMilli()},
+		if !ln.checkAlive(interval) {
+			return
 		}
-		if err := ln.SendWS(ctx, payload, false); err != nil {
+		if err := ln.sendPing(ctx); err != nil {
 			ln.emitError(ctx, errs.WrapZCA("failed to send ping:", "ping", err))
 		}
 	}
 
-	interval := ln.sc.WSPingInterval()
-	if interval <= 0 {
-		return
-	}
-
 	stop := startPingLoop(ctx, interval, ping)
 	ln.pingStopper = &stop
 }
 
 func (ln *listener) handleMessages(ctx context.Context, body BaseWSMessage) {
-	eventData, err := decodeEventData[events.MessageEventData](body, ln.cipherKey)
+	eventData, err := decodeEventData[events.MessageEventData](body, ln.getCipherKey())
 	if err != nil {
 		err = errs.WrapZCA("Failed to decode event data:", "listener.handleMessages", err)
 		ln.emitError(ctx, err)
@@ -102,19 +117,20 @@ func (ln *listener) handleMessages(ctx context.Context, body BaseWSMessage) {
 			if undo.IsSelf && !ln.selfListen {
 				continue
 			}
-			emit(ctx, ln.ch.Undo, undo)
+			ln.emitLive(func() { emit(ctx, ln.ch.Undo, undo) })
 		} else if msg.Message != nil {
 			message := model.NewUserMessage(uid, *msg.Message)
+			ln.recovery.mark(recoveryMessage, model.ThreadTypeUser, message.Data.MsgID)
 			if message.IsSelf() && !ln.selfListen {
 				continue
 			}
-			emit(ctx, ln.ch.Message, model.Message(message))
+			ln.emitLive(func() { emit(ctx, ln.ch.Message, model.Message(message)) })
 		}
 	}
 }
 
 func (ln *listener) handleOldMessages(ctx context.Context, body BaseWSMessage) {
-	eventData, err := decodeEventData[events.OldMessagesEventData](body, ln.cipherKey)
+	eventData, err := decodeEventData[events.OldMessagesEventData](body, ln.getCipherKey())
 	if err != nil {
 		err = errs.WrapZCA("Failed to decode event data:", "listener.handleOldMessages", err)
 		ln.emitError(ctx, err)
@@ -140,7 +156,7 @@ func (ln *listener) handleOldMessages(ctx context.Context, body BaseWSMessage) {
 }
 
 func (ln *listener) handleMessagesStatus(ctx context.Context, body BaseWSMessage) {
-	eventData, err := decodeEventData[events.MessageStatusEventData](body, ln.cipherKey)
+	eventData, err := decodeEventData[events.MessageStatusEventData](body, ln.getCipherKey())
 	if err != nil {
 		err = errs.WrapZCA("Failed to decode event data:", "listener.handleMessagesStatus", err)
 		ln.emitError(ctx, err)
@@ -165,7 +181,7 @@ func (ln *listener) handleMessagesStatus(ctx context.Context, body BaseWSMessage
 }
 
 func (ln *listener) handleGroupMessages(ctx context.Context, body BaseWSMessage) {
-	eventData, err := decodeEventData[events.GroupMessageEventData](body, ln.cipherKey)
+	eventData, err := decodeEventData[events.GroupMessageEventData](body, ln.getCipherKey())
 	if err != nil {
 		err = errs.WrapZCA("Failed to decode event data:", "listener.handleGroupMessages", err)
 		ln.emitError(ctx, err)
@@ -178,19 +194,20 @@ func (ln *listener) handleGroupMessages(ctx context.Context, body BaseWSMessage)
 			if undo.IsSelf && !ln.selfListen {
 				continue
 			}
-			emit(ctx, ln.ch.Undo, undo)
+			ln.emitLive(func() { emit(ctx, ln.ch.Undo, undo) })
 		} else if msg.Message != nil {
 			message := model.NewGroupMessage(ln.sc.UID(), *msg.Message)
+			ln.recovery.mark(recoveryMessage, model.ThreadTypeGroup, message.Data.MsgID)
 			if message.IsSelf() && !ln.selfListen {
 				continue
 			}
-			emit(ctx, ln.ch.Message, model.Message(message))
+			ln.emitLive(func() { emit(ctx, ln.ch.Message, model.Message(message)) })
 		}
 	}
 }
 
 func (ln *listener) handleGroupMessagesStatus(ctx context.Context, body BaseWSMessage) {
-	eventData, err := decodeEventData[events.GroupMessageStatusEventData](body, ln.cipherKey)
+	eventData, err := decodeEventData[events.GroupMessageStatusEventData](body, ln.getCipherKey())
 	if err != nil {
 		err = errs.WrapZCA("Failed to decode event data:", "listener.handleGroupMessagesStatus", err)
 		ln.emitError(ctx, err)
@@ -223,7 +240,7 @@ func (ln *listener) handleGroupMessagesStatus(ctx context.Context, body BaseWSMe
 }
 
 func (ln *listener) handleReactions(ctx context.Context, body BaseWSMessage) {
-	eventData, err := decodeEventData[events.ReactionEventData](body, ln.cipherKey)
+	eventData, err := decodeEventData[events.ReactionEventData](body, ln.getCipherKey())
 	if err != nil {
 		err = errs.WrapZCA("Failed to decode event data:", "listener.handleReaction", err)
 		ln.emitError(ctx, err)
@@ -233,22 +250,24 @@ func (ln *listener) handleReactions(ctx context.Context, body BaseWSMessage) {
 	uid := ln.sc.UID()
 	for _, r := range eventData.Data.Reactions {
 		reaction := model.NewReaction(uid, r, model.ThreadTypeUser)
+		ln.recovery.mark(recoveryReaction, model.ThreadTypeUser, r.MsgID)
 		if reaction.IsSelf && !ln.selfListen {
 			continue
 		}
-		emit(ctx, ln.ch.Reaction, reaction)
+		ln.emitLive(func() { emit(ctx, ln.ch.Reaction, reaction) })
 	}
 	for _, r := range eventData.Data.GroupReactions {
 		reaction := model.NewReaction(uid, r, model.ThreadTypeGroup)
+		ln.recovery.mark(recoveryReaction, model.ThreadTypeGroup, r.MsgID)
 		if reaction.IsSelf && !ln.selfListen {
 			continue
 		}
-		emit(ctx, ln.ch.Reaction, reaction)
+		ln.emitLive(func() { emit(ctx, ln.ch.Reaction, reaction) })
 	}
 }
 
 func (ln *listener) handleOldReactions(ctx context.Context, body BaseWSMessage) {
-	eventData, err := decodeEventData[events.ReactionEventData](body, ln.cipherKey)
+	eventData, err := decodeEventData[events.ReactionEventData](body, ln.getCipherKey())
 	if err != nil {
 		err = errs.WrapZCA("Failed to decode event data", "listener.handleOldReactions", err)
 		ln.emitError(ctx, err)
@@ -274,7 +293,7 @@ func (ln *listener) handleOldReactions(ctx context.Context, body BaseWSMessage)
 }
 
 func (ln *listener) handleActions(ctx context.Context, body BaseWSMessage) {
-	eventData, err := decodeEventData[events.ActionEventData](body, ln.cipherKey)
+	eventData, err := decodeEventData[events.ActionEventData](body, ln.getCipherKey())
 	if err != nil {
 		err = errs.WrapZCA("Failed to decode event data:", "listener.handleActions", err)
 		ln.emitError(ctx, err)
@@ -300,7 +319,7 @@ func (ln *listener) handleActions(ctx context.Context, body BaseWSMessage) {
 }
 
 func (ln *listener) handleControls(ctx context.Context, body BaseWSMessage) {
-	eventData, err := decodeEventData[events.ControlEventData](body, ln.cipherKey)
+	eventData, err := decodeEventData[events.ControlEventData](body, ln.getCipherKey())
 	if err != nil {
 		err = errs.WrapZCA("Failed to decode event data:", "listener.handleControls", err)
 		ln.emitError(ctx, err)
@@ -0,0 +1,275 @@
+package listener
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/Amrakk/zcago/config"
+	"github.com/Amrakk/zcago/errs"
+	"github.com/Amrakk/zcago/model"
+)
+
+// spillQueue is the on-disk overflow queue of a channel using BackpressureSpillToDisk.
+// Records are length-prefixed and appended to a temporary file, which is removed
+// once every record has been delivered or when the listener stops.
+type spillQueue[T any] struct {
+	mu    sync.Mutex
+	dir   string
+	name  string
+	codec *spillCodec[T]
+	drop  func(ctx context.Context, cause error)
+
+	file     *os.File
+	readOff  int64
+	writeOff int64
+	count    int
+	draining bool
+
+	// Draining outlives the connection the events came from, it only ends with
+	// the queue or when close is called by Stop.
+	cancel  context.CancelFunc
+	drained chan struct{} // Closed when the running drain returns
+}
+
+// push sends obj directly when nothing is spilled and ch has room, otherwise
+// appends it to the queue so that events keep their order.
+func (q *spillQueue[T]) push(ctx context.Context, ch chan T, obj T) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.count == 0 {
+		select {
+		case ch <- obj:
+			return nil
+		default:
+		}
+	}
+
+	data, err := q.codec.encode(obj)
+	if err != nil {
+		return errs.WrapZCA("failed to encode event", "listener.spill", err)
+	}
+
+	if q.file == nil {
+		f, err := os.CreateTemp(q.dir, "zcago-"+q.name+"-*.spill")
+		if err != nil {
+			return errs.WrapZCA("failed to create spill file", "listener.spill", err)
+		}
+		q.file = f
+	}
+
+	record := binary.AppendUvarint(nil, uint64(len(data)))
+	record = append(record, data...)
+	if _, err := q.file.WriteAt(record, q.writeOff); err != nil {
+		return errs.WrapZCA("failed to write spill file", "listener.spill", err)
+	}
+	q.writeOff += int64(len(record))
+	q.count++
+
+	if !q.draining {
+		var dctx context.Context
+		dctx, q.cancel = context.WithCancel(context.Background())
+		q.drained = make(chan struct{})
+		q.draining = true
+		go q.drain(dctx, ch, q.drained)
+	}
+	return nil
+}
+
+// drain delivers spilled records into ch until the queue is empty or close is called.
+// A record is only removed from the queue once it has been delivered.
+func (q *spillQueue[T]) drain(ctx context.Context, ch chan T, drained chan struct{}) {
+	defer close(drained)
+
+	for {
+		q.mu.Lock()
+		if q.count == 0 || ctx.Err() != nil {
+			q.draining = false
+			q.cancel()
+			if q.count == 0 {
+				q.removeFileLocked()
+			}
+			q.mu.Unlock()
+			return
+		}
+		obj, next, err := q.peekLocked()
+		q.mu.Unlock()
+
+		if err == nil {
+			select {
+			case ch <- obj:
+			case <-ctx.Done():
+				continue
+			}
+		}
+
+		q.mu.Lock()
+		if err != nil && next == 0 {
+			// The file can't be read any further, give up on what is left
+			for range q.count {
+				q.drop(ctx, err)
+			}
+			q.count = 0
+		} else {
+			q.readOff = next
+			q.count--
+			if err != nil {
+				q.drop(ctx, err)
+			}
+		}
+		q.mu.Unlock()
+	}
+}
+
+// peekLocked decodes the record at readOff and returns the offset following it,
+// or 0 when the record boundary itself can't be read.
+func (q *spillQueue[T]) peekLocked() (T, int64, error) {
+	var zero T
+
+	head := make([]byte, binary.MaxVarintLen64)
+	n, err := q.file.ReadAt(head, q.readOff)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return zero, 0, errs.WrapZCA("failed to read spill file", "listener.spill", err)
+	}
+	size, k := binary.Uvarint(head[:n])
+	if k <= 0 {
+		return zero, 0, errs.NewZCA("corrupted spill file", "listener.spill")
+	}
+
+	data := make([]byte, size)
+	if _, err := q.file.ReadAt(data, q.readOff+int64(k)); err != nil {
+		return zero, 0, errs.WrapZCA("failed to read spill file", "listener.spill", err)
+	}
+	next := q.readOff + int64(k) + int64(size)
+
+	obj, err := q.codec.decode(data)
+	if err != nil {
+		return zero, next, errs.WrapZCA("failed to decode spilled event", "listener.spill", err)
+	}
+	return obj, next, nil
+}
+
+// close stops the drain and deletes the spill file, discarding the events not delivered yet.
+func (q *spillQueue[T]) close() {
+	q.mu.Lock()
+	if q.draining {
+		q.cancel()
+	}
+	drained := q.drained
+	q.mu.Unlock()
+
+	if drained != nil {
+		<-drained
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.count = 0
+	q.removeFileLocked()
+}
+
+func (q *spillQueue[T]) removeFileLocked() {
+	if q.file == nil {
+		return
+	}
+	_ = q.file.Close()
+	_ = os.Remove(q.file.Name())
+	q.file = nil
+	q.readOff, q.writeOff = 0, 0
+}
+
+type spillCodec[T any] struct {
+	encode func(T) ([]byte, error)
+	decode func([]byte) (T, error)
+}
+
+// jsonCodec serializes events made only of exported fields.
+func jsonCodec[T any]() *spillCodec[T] {
+	return &spillCodec[T]{
+		encode: func(v T) ([]byte, error) { return json.Marshal(v) },
+		decode: func(data []byte) (T, error) {
+			var v T
+			err := json.Unmarshal(data, &v)
+			return v, err
+		},
+	}
+}
+
+// spilledMessage keeps what is needed to rebuild a model.Message, whose thread
+// and self flag are unexported.
+type spilledMessage struct {
+	Group     bool                `json:"group"`
+	Self      bool                `json:"self"`
+	Recovered bool                `json:"recovered,omitempty"`
+	Data      model.TGroupMessage `json:"data"`
+}
+
+func toSpilledMessage(m model.Message) spilledMessage {
+	switch m := m.(type) {
+	case model.UserMessage:
+		return spilledMessage{Self: m.IsSelf(), Recovered: m.Recovered, Data: model.TGroupMessage{TMessage: m.Data}}
+	case model.GroupMessage:
+		return spilledMessage{Group: true, Self: m.IsSelf(), Recovered: m.Recovered, Data: m.Data}
+	}
+	return spilledMessage{}
+}
+
+func fromSpilledMessage(uid string, s spilledMessage) model.Message {
+	if s.Self {
+		s.Data.UIDFrom = config.DefaultUIDSelf
+	}
+	if s.Group {
+		msg := model.NewGroupMessage(uid, s.Data)
+		msg.Recovered = s.Recovered
+		return msg
+	}
+	msg := model.NewUserMessage(uid, s.Data.TMessage)
+	msg.Recovered = s.Recovered
+	return msg
+}
+
+func messageCodec(uid func() string) *spillCodec[model.Message] {
+	return &spillCodec[model.Message]{
+		encode: func(m model.Message) ([]byte, error) { return json.Marshal(toSpilledMessage(m)) },
+		decode: func(data []byte) (model.Message, error) {
+			var s spilledMessage
+			if err := json.Unmarshal(data, &s); err != nil {
+				return nil, err
+			}
+			return fromSpilledMessage(uid(), s), nil
+		},
+	}
+}
+
+func oldMessagesCodec(uid func() string) *spillCodec[model.OldMessages] {
+	type spilled struct {
+		Messages   []spilledMessage `json:"messages"`
+		ThreadType model.ThreadType `json:"threadType"`
+	}
+
+	return &spillCodec[model.OldMessages]{
+		encode: func(m model.OldMessages) ([]byte, error) {
+			s := spilled{Messages: make([]spilledMessage, 0, len(m.Messages)), ThreadType: m.ThreadType}
+			for _, msg := range m.Messages {
+				s.Messages = append(s.Messages, toSpilledMessage(msg))
+			}
+			return json.Marshal(s)
+		},
+		decode: func(data []byte) (model.OldMessages, error) {
+			var s spilled
+			if err := json.Unmarshal(data, &s); err != nil {
+				return model.OldMessages{}, err
+			}
+			messages := make([]model.Message, 0, len(s.Messages))
+			for _, msg := range s.Messages {
+				messages = append(messages, fromSpilledMessage(uid(), msg))
+			}
+			return model.NewOldMessage(messages, s.ThreadType), nil
+		},
+	}
+}
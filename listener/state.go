@@ -0,0 +1,148 @@
+package listener
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ConnState is a step of the listener connection lifecycle.
+type ConnState uint8
+
+const (
+	// StateIdle is the state of a listener that was never started, or whose
+	// Start failed.
+	StateIdle ConnState = iota
+	// StateDialing is set while the websocket connection is being opened.
+	StateDialing
+	// StateConnected is set once the websocket is open, before the cipher key
+	// needed to decode events is received.
+	StateConnected
+	// StateAuthenticated is set once the cipher key is received, events flow.
+	StateAuthenticated
+	// StateReconnecting is set while waiting to dial again after the connection
+	// dropped, see StateInfo.Attempt and StateInfo.Delay.
+	StateReconnecting
+	// StateClosed is set when the listener stopped for good, see StateInfo.Reason.
+	StateClosed
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateIdle:
+		return "idle"
+	case StateDialing:
+		return "dialing"
+	case StateConnected:
+		return "connected"
+	case StateAuthenticated:
+		return "authenticated"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateClosed:
+		return "closed"
+	}
+	return fmt.Sprintf("ConnState(%d)", uint8(s))
+}
+
+// RetryBudget is the number of reconnections allowed for a close code.
+type RetryBudget struct {
+	Used int
+	Max  int
+}
+
+// StateInfo describes the connection state and when it was entered.
+type StateInfo struct {
+	State ConnState
+	Since time.Time
+
+	Endpoint  string    // Websocket URL dialed, without its query
+	Transport Transport // Connection used, or to be used by the next dial
+
+	Attempt int           // Reconnection attempt, from 1, while StateReconnecting
+	Delay   time.Duration // Wait before dialing again, while StateReconnecting
+	Reason  CloseInfo     // Close that led to StateReconnecting or StateClosed
+
+	Retries map[int]RetryBudget // Reconnection budget per close code
+}
+
+type connState struct {
+	mu   sync.RWMutex
+	info StateInfo
+}
+
+// State returns the current connection state along with the endpoint and the
+// retry budget.
+func (ln *listener) State() StateInfo {
+	ln.state.mu.RLock()
+	info := ln.state.info
+	ln.state.mu.RUnlock()
+
+	ln.mu.RLock()
+	defer ln.mu.RUnlock()
+	info.Endpoint = ln.endpointLocked()
+	info.Transport = ln.transport
+	info.Retries = ln.retryBudgetLocked()
+	return info
+}
+
+// SubscribeState subscribes to the state changes, the value of each event is a
+// StateInfo.
+func (ln *listener) SubscribeState(opts ...SubscribeOption) (<-chan Event, func()) {
+	return ln.Subscribe(OfKind(KindState), opts...)
+}
+
+func (ln *listener) setState(ctx context.Context, info StateInfo) {
+	ln.mu.RLock()
+	info = ln.stateLocked(info)
+	ln.mu.RUnlock()
+
+	ln.publish(ctx, KindState, info)
+}
+
+// stateLocked records info for callers holding ln.mu and returns it completed.
+// The caller publishes it once ln.mu is released, as a Block subscriber may be
+// calling State meanwhile.
+func (ln *listener) stateLocked(info StateInfo) StateInfo {
+	info.Since = time.Now()
+	info.Endpoint = ln.endpointLocked()
+	info.Transport = ln.transport
+	info.Retries = ln.retryBudgetLocked()
+
+	ln.state.mu.Lock()
+	ln.state.info = info
+	ln.state.mu.Unlock()
+	return info
+}
+
+func (ln *listener) endpointLocked() string {
+	if ln.rotateCount < len(ln.urls) {
+		return ln.urls[ln.rotateCount]
+	}
+	return ""
+}
+
+func (ln *listener) retryBudgetLocked() map[int]RetryBudget {
+	budget := make(map[int]RetryBudget, len(ln.retryStates))
+	for reason, st := range ln.retryStates {
+		code, err := strconv.Atoi(reason)
+		if err != nil || st == nil {
+			continue
+		}
+		budget[code] = RetryBudget{Used: st.count, Max: st.max}
+	}
+	return budget
+}
+
+// retryAttempt returns the number of reconnections made so far for code.
+func (ln *listener) retryAttempt(code int) int {
+	ln.mu.RLock()
+	defer ln.mu.RUnlock()
+
+	if st, ok := ln.retryStates[strconv.Itoa(code)]; ok && st != nil {
+		return st.count
+	}
+	return 0
+}
@@ -0,0 +1,139 @@
+package listener
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Amrakk/zcago/model"
+)
+
+// EventFilter selects the events delivered to a subscriber, nil matches all.
+type EventFilter func(ev Event) bool
+
+// OfKind matches events of any of the given kinds.
+func OfKind(kinds ...EventKind) EventFilter {
+	return func(ev Event) bool { return slices.Contains(kinds, ev.Kind) }
+}
+
+// InThread matches events of the given thread.
+func InThread(threadID string, threadType model.ThreadType) EventFilter {
+	return func(ev Event) bool {
+		id, typ, ok := ev.Thread()
+		return ok && id == threadID && typ == threadType
+	}
+}
+
+type SubscribeOption func(*subscribeOptions)
+
+type subscribeOptions struct {
+	buffer int
+	policy BackpressurePolicy
+}
+
+// WithSubscriberBuffer sets the buffer size of the subscriber channel, 64 by default.
+func WithSubscriberBuffer(n int) SubscribeOption {
+	return func(o *subscribeOptions) { o.buffer = n }
+}
+
+// WithSubscriberPolicy sets what happens to events when the subscriber channel is
+// full, BackpressureDropOldest by default. BackpressureSpillToDisk is not available
+// for subscribers and behaves like BackpressureDropOldest.
+func WithSubscriberPolicy(p BackpressurePolicy) SubscribeOption {
+	return func(o *subscribeOptions) { o.policy = p }
+}
+
+type subscriber struct {
+	filter EventFilter
+	out    *outlet[Event]
+	done   chan struct{}
+}
+
+type subscribers struct {
+	mu   sync.RWMutex
+	list []*subscriber
+}
+
+// Subscribe returns a channel receiving every event matching filter, in addition
+// to the per-kind channels and any other subscriber. Listeners only consumed this
+// way should be configured with WithSubscribeOnly. Each subscriber has its own
+// buffer and backpressure policy. unsubscribe stops the delivery and closes the
+// channel; it is safe to call more than once.
+func (ln *listener) Subscribe(filter EventFilter, opts ...SubscribeOption) (<-chan Event, func()) {
+	cfg := subscribeOptions{buffer: 64}
+	for _, fn := range opts {
+		if fn != nil {
+			fn(&cfg)
+		}
+	}
+	if cfg.policy == BackpressureSpillToDisk {
+		cfg.policy = BackpressureDropOldest
+	}
+
+	out, _ := newOutlet[Event]("Subscriber", max(cfg.buffer, 0), cfg.policy, nil, "")
+	out.report = ln.reportDrop
+	sub := &subscriber{filter: filter, out: out, done: make(chan struct{})}
+
+	ln.subs.mu.Lock()
+	ln.subs.list = append(ln.subs.list, sub)
+	ln.subs.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			// Unblocks a pending delivery before taking the write lock
+			close(sub.done)
+
+			ln.subs.mu.Lock()
+			ln.subs.list = slices.DeleteFunc(ln.subs.list, func(s *subscriber) bool { return s == sub })
+			ln.subs.mu.Unlock()
+
+			close(out.ch)
+		})
+	}
+
+	return out.ch, unsubscribe
+}
+
+// SubscribeThread subscribes to the events of a single thread.
+func (ln *listener) SubscribeThread(threadID string, threadType model.ThreadType, opts ...SubscribeOption) (<-chan Event, func()) {
+	return ln.Subscribe(InThread(threadID, threadType), opts...)
+}
+
+// publish fans ev out to the matching subscribers.
+func (ln *listener) publish(ctx context.Context, kind EventKind, value any) {
+	ln.subs.mu.RLock()
+	defer ln.subs.mu.RUnlock()
+
+	if len(ln.subs.list) == 0 {
+		return
+	}
+
+	ev := Event{Kind: kind, Value: value, Received: time.Now()}
+	for _, sub := range ln.subs.list {
+		if sub.filter != nil && !sub.filter(ev) {
+			continue
+		}
+
+		if sub.out.policy == BackpressureBlock {
+			select {
+			case sub.out.ch <- ev:
+			case <-sub.done:
+			case <-ctx.Done():
+			}
+			continue
+		}
+		emit(ctx, sub.out, ev)
+	}
+}
+
+// reportDrop sends err to the Error channel without publishing it, so that
+// subscribers dropping errors don't feed back into themselves.
+func (ln *listener) reportDrop(_ context.Context, err error) {
+	select {
+	case ln.ch.Error <- err:
+	default:
+		ln.ch.errorDrops.Add(1)
+	}
+}
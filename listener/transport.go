@@ -0,0 +1,244 @@
+package listener
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Amrakk/zcago/config"
+	"github.com/Amrakk/zcago/errs"
+	"github.com/Amrakk/zcago/internal/logger"
+	"github.com/Amrakk/zcago/internal/websocketx"
+)
+
+// closeTransportSwitch closes the long-polling connection once the websocket
+// is reachable again, the listener then reconnects within the retry budget of
+// the code.
+const closeTransportSwitch = 4000
+
+// Transport is the kind of connection events are read from.
+type Transport uint8
+
+const (
+	TransportWebSocket Transport = iota
+	// TransportLongPolling polls the websocket endpoint over HTTP, used when
+	// websockets can't be dialed and the experimental fallback is enabled, see
+	// FallbackOptions.
+	TransportLongPolling
+)
+
+func (t Transport) String() string {
+	switch t {
+	case TransportWebSocket:
+		return "websocket"
+	case TransportLongPolling:
+		return "long-polling"
+	}
+	return fmt.Sprintf("Transport(%d)", uint8(t))
+}
+
+// FallbackOptions configures the long-polling fallback. It is never used when
+// the server settings disable long-polling (disable_lp).
+//
+// Experimental: the long-polling framing is not the one of the official
+// clients, see websocketx.DialLongPoll, so it needs an endpoint speaking it.
+type FallbackOptions struct {
+	Enabled bool
+	// After is the number of consecutive websocket dial failures before falling
+	// back to long-polling, 3 by default.
+	After int
+	// RetryWebSocket is how often the websocket is tried again while on
+	// long-polling, 5 minutes by default. The listener only switches back when
+	// the server settings allow it (reconnect_after_fallback).
+	RetryWebSocket time.Duration
+	// URL returns the long-polling URL of a websocket URL, by default the same
+	// URL over http(s).
+	URL func(wsURL string) string
+}
+
+func defaultFallbackOptions() FallbackOptions {
+	return FallbackOptions{
+		After:          3,
+		RetryWebSocket: 5 * time.Minute,
+		URL:            longPollURL,
+	}
+}
+
+func mergeFallbackOptions(o FallbackOptions) FallbackOptions {
+	def := defaultFallbackOptions()
+	if o.After <= 0 {
+		o.After = def.After
+	}
+	if o.RetryWebSocket <= 0 {
+		o.RetryWebSocket = def.RetryWebSocket
+	}
+	if o.URL == nil {
+		o.URL = def.URL
+	}
+	return o
+}
+
+func longPollURL(wsURL string) string {
+	if rest, ok := strings.CutPrefix(wsURL, "wss://"); ok {
+		return "https://" + rest
+	}
+	if rest, ok := strings.CutPrefix(wsURL, "ws://"); ok {
+		return "http://" + rest
+	}
+	return wsURL
+}
+
+// dial opens the connection of Start and returns the transport used. The
+// websocket is dialed until it failed FallbackOptions.After times in a row, then
+// long-polling is used. ln.mu is only held to read and record the dial state.
+func (ln *listener) dial(ctx context.Context) (websocketx.Client, Transport, error) {
+	fallback := ln.fallbackAllowed()
+
+	ln.mu.RLock()
+	wsURL, transport, failures := ln.wsURL, ln.transport, ln.dialFailures
+	ln.mu.RUnlock()
+
+	if transport == TransportWebSocket || !fallback {
+		attempts := 1
+		if fallback {
+			attempts = max(ln.fallback.After-failures, 1)
+		}
+
+		var lastErr error
+		for i := range attempts {
+			if i > 0 {
+				select {
+				case <-ctx.Done():
+					return nil, 0, errs.WrapZCA("context cancelled", "listener.Start", ctx.Err())
+				case <-time.After(time.Duration(i) * 500 * time.Millisecond):
+				}
+			}
+
+			client, err := ln.createWebSocketConnection(ctx, wsURL)
+
+			ln.mu.Lock()
+			if err == nil {
+				ln.transport = TransportWebSocket
+				ln.dialFailures = 0
+			} else {
+				ln.dialFailures++
+				failures = ln.dialFailures
+			}
+			ln.mu.Unlock()
+
+			if err == nil {
+				return client, TransportWebSocket, nil
+			}
+			lastErr = err
+		}
+		if !fallback || failures < ln.fallback.After {
+			return nil, 0, lastErr
+		}
+
+		logger.Log(ln.sc).Warnf("Websocket dial failed %d times, falling back to long-polling", failures)
+	}
+
+	client, err := ln.createLongPollConnection(ctx, wsURL)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ln.mu.Lock()
+	ln.transport = TransportLongPolling
+	ln.mu.Unlock()
+
+	return client, TransportLongPolling, nil
+}
+
+// shouldProbeWebSocket reports whether the websocket is to be tried again while
+// connected over transport.
+func (ln *listener) shouldProbeWebSocket(transport Transport) bool {
+	if transport != TransportLongPolling {
+		return false
+	}
+	s := ln.sc.Settings()
+	return s != nil && s.Features.Socket.ReconnectAfterFB
+}
+
+func (ln *listener) fallbackAllowed() bool {
+	if !ln.fallback.Enabled {
+		return false
+	}
+	s := ln.sc.Settings()
+	return s == nil || !s.Features.Socket.DisableLP
+}
+
+func (ln *listener) createLongPollConnection(ctx context.Context, wsURL string) (websocketx.Client, error) {
+	lpURL := ln.fallback.URL(wsURL)
+	u, err := url.Parse(lpURL)
+	if err != nil {
+		return nil, errs.WrapZCA("parse long-polling URL failed", "listener.createLongPollConnection", err)
+	}
+
+	client, err := websocketx.DialLongPoll(ctx, lpURL, &websocketx.Options{
+		Header:      ln.connectionHeader(u),
+		HTTPClient:  ln.sc.Client(),
+		BlockOnFull: ln.blockReads,
+	})
+	if err != nil {
+		return nil, errs.WrapZCA("long-polling dial failed", "listener.createLongPollConnection", err)
+	}
+
+	return client, nil
+}
+
+func (ln *listener) connectionHeader(u *url.URL) http.Header {
+	h := make(http.Header)
+	h.Set("Accept-Encoding", "gzip, deflate, br, zstd")
+	h.Set("Accept-Language", "en-US,en;q=0.9")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Host", u.Host)
+	h.Set("Origin", config.DefaultURL.String())
+	h.Set("Pragma", "no-cache")
+	h.Set("User-Agent", ln.userAgent)
+	return h
+}
+
+// probeWebSocket tries the websocket every FallbackOptions.RetryWebSocket while
+// lp is the active connection. Once it can be dialed, lp is closed so that the
+// listener reconnects over the websocket.
+func (ln *listener) probeWebSocket(ctx context.Context, lp websocketx.Client) {
+	defer ln.wg.Done()
+
+	ticker := time.NewTicker(ln.fallback.RetryWebSocket)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if ln.getClient() != lp {
+			return
+		}
+
+		ln.mu.RLock()
+		wsURL := ln.wsURL
+		ln.mu.RUnlock()
+
+		probe, err := ln.createWebSocketConnection(ctx, wsURL)
+		if err != nil {
+			continue
+		}
+		probe.Close(ZaloManualClosure, "")
+
+		ln.mu.Lock()
+		ln.transport = TransportWebSocket
+		ln.dialFailures = 0
+		ln.mu.Unlock()
+
+		logger.Log(ln.sc).Verbose("Websocket is reachable again, leaving long-polling")
+		lp.Close(closeTransportSwitch, "websocket available")
+		return
+	}
+}
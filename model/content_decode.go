@@ -0,0 +1,358 @@
+package model
+
+import (
+	"cmp"
+	"encoding/json"
+	"strconv"
+)
+
+// DecodedContent is the typed content of a received message, returned by Decode.
+// Use a type switch over the *Content variants of this file.
+type DecodedContent interface {
+	decodedContent()
+}
+
+type (
+	TextContent struct {
+		Text     string
+		Styles   []MessageStyle
+		Mentions []TMention
+	}
+	PhotoContent struct {
+		Caption  string
+		URL      string // Normal quality URL
+		HDURL    string
+		HDSize   int64 // Size of the HD variant, 0 when unknown
+		RawURL   string
+		ThumbURL string
+		Width    int
+		Height   int
+	}
+	FileContent struct {
+		Name     string
+		URL      string
+		Ext      string
+		Size     int64
+		Checksum string
+	}
+	StickerContent struct {
+		ID         int
+		CategoryID int
+		Type       int
+	}
+	GIFContent struct {
+		URL      string
+		ThumbURL string
+		Width    int
+		Height   int
+	}
+	VoiceContent struct {
+		URL      string
+		Duration int // Duration in milliseconds
+	}
+	VideoContent struct {
+		URL      string
+		ThumbURL string
+		Duration int // Duration in milliseconds
+		Width    int
+		Height   int
+	}
+	LinkContent struct {
+		URL         string
+		Title       string
+		Description string
+		ThumbURL    string
+	}
+	LocationContent struct {
+		Latitude  float64
+		Longitude float64
+		Title     string
+		Address   string
+	}
+	ContactCardContent struct {
+		UserID    string
+		Name      string
+		AvatarURL string
+		Phone     string
+		QRCodeURL string
+	}
+	BankCardContent struct {
+		BinBank       int
+		AccountNumber string
+		AccountName   string
+	}
+	PollContent struct {
+		PollID   int64
+		Question string
+	}
+	TodoContent struct {
+		Content   string
+		Assignees []string
+		DueDate   int64 // Unix milliseconds, 0 when unset
+	}
+	// RecommendedContent is a chat.recommended message other than a link or contact card.
+	RecommendedContent struct {
+		Action      string
+		Title       string
+		Description string
+		Href        string
+		ThumbURL    string
+		Params      map[string]any
+	}
+	// UnknownContent carries the raw content of message types without a typed variant.
+	UnknownContent struct {
+		MsgType string
+		Content Content
+	}
+)
+
+func (TextContent) decodedContent()        {}
+func (PhotoContent) decodedContent()       {}
+func (FileContent) decodedContent()        {}
+func (StickerContent) decodedContent()     {}
+func (GIFContent) decodedContent()         {}
+func (VoiceContent) decodedContent()       {}
+func (VideoContent) decodedContent()       {}
+func (LinkContent) decodedContent()        {}
+func (LocationContent) decodedContent()    {}
+func (ContactCardContent) decodedContent() {}
+func (BankCardContent) decodedContent()    {}
+func (PollContent) decodedContent()        {}
+func (TodoContent) decodedContent()        {}
+func (RecommendedContent) decodedContent() {}
+func (UnknownContent) decodedContent()     {}
+
+func (m UserMessage) Decode() DecodedContent  { return m.Data.Decode() }
+func (m GroupMessage) Decode() DecodedContent { return m.Data.decode(m.Data.Mentions) }
+
+// Decode returns the typed content of the message according to its MsgType.
+// Fields missing from the payload are left zero.
+func (m TMessage) Decode() DecodedContent {
+	return m.decode(nil)
+}
+
+func (m TMessage) decode(mentions []*TMention) DecodedContent {
+	obj := m.Content.object()
+	params := contentParams(obj)
+
+	switch m.MsgType {
+	case "webchat", "chat.text":
+		text := TextContent{
+			Styles:   m.TextStyles(),
+			Mentions: mergeMentions(mentions, m.TextMentions()),
+		}
+		if m.Content.String != nil {
+			text.Text = *m.Content.String
+		} else {
+			text.Text = jsonStr(obj, "title")
+		}
+		return text
+
+	case "chat.photo":
+		return PhotoContent{
+			Caption:  jsonStr(obj, "title", "description"),
+			URL:      jsonStr(obj, "href"),
+			HDURL:    jsonStr(params, "hd", "hdUrl"),
+			HDSize:   jsonNum(params, "hdSize"),
+			RawURL:   jsonStr(params, "rawUrl"),
+			ThumbURL: jsonStr(obj, "thumb"),
+			Width:    int(jsonNum(params, "width")),
+			Height:   int(jsonNum(params, "height")),
+		}
+
+	case "share.file":
+		return FileContent{
+			Name:     jsonStr(obj, "title"),
+			URL:      jsonStr(obj, "href"),
+			Ext:      jsonStr(params, "fileExt"),
+			Size:     jsonNum(params, "fileSize"),
+			Checksum: jsonStr(params, "checksum", "checkSum"),
+		}
+
+	case "chat.sticker":
+		return StickerContent{
+			ID:         int(jsonNum(obj, "id")),
+			CategoryID: int(jsonNum(obj, "catId")),
+			Type:       int(jsonNum(obj, "type")),
+		}
+
+	case "chat.gif":
+		return GIFContent{
+			URL:      jsonStr(obj, "href"),
+			ThumbURL: jsonStr(obj, "thumb"),
+			Width:    int(jsonNum(params, "width")),
+			Height:   int(jsonNum(params, "height")),
+		}
+
+	case "chat.voice":
+		return VoiceContent{
+			URL:      jsonStr(obj, "href"),
+			Duration: int(jsonNum(params, "duration")),
+		}
+
+	case "chat.video.msg":
+		return VideoContent{
+			URL:      jsonStr(obj, "href"),
+			ThumbURL: jsonStr(obj, "thumb"),
+			Duration: int(jsonNum(params, "duration")),
+			Width:    int(jsonNum(params, "video_width", "width")),
+			Height:   int(jsonNum(params, "video_height", "height")),
+		}
+
+	case "chat.link":
+		return decodeLink(obj)
+
+	case "chat.location.new":
+		return LocationContent{
+			Latitude:  jsonFloat(params, "latitude", "lat"),
+			Longitude: jsonFloat(params, "longitude", "lng", "long"),
+			Title:     cmp.Or(jsonStr(obj, "title"), jsonStr(params, "title")),
+			Address:   cmp.Or(jsonStr(obj, "description"), jsonStr(params, "address")),
+		}
+
+	case "chat.recommended":
+		switch jsonStr(obj, "action") {
+		case "recommened.link":
+			return decodeLink(obj)
+		case "recommened.user":
+			return ContactCardContent{
+				UserID:    jsonStr(params, "uid", "userId", "contactUid"),
+				Name:      jsonStr(obj, "title"),
+				AvatarURL: jsonStr(obj, "thumb"),
+				Phone:     jsonStr(params, "phone"),
+				QRCodeURL: jsonStr(params, "qrCodeUrl"),
+			}
+		}
+		return RecommendedContent{
+			Action:      jsonStr(obj, "action"),
+			Title:       jsonStr(obj, "title"),
+			Description: jsonStr(obj, "description"),
+			Href:        jsonStr(obj, "href"),
+			ThumbURL:    jsonStr(obj, "thumb"),
+			Params:      params,
+		}
+
+	case "chat.webcontent":
+		if jsonStr(obj, "action") == "zinstant.bankcard" {
+			return BankCardContent{
+				BinBank:       int(jsonNum(params, "binBank")),
+				AccountNumber: jsonStr(params, "numAccBank"),
+				AccountName:   jsonStr(params, "nameAccBank"),
+			}
+		}
+
+	case "group.poll":
+		return PollContent{
+			PollID:   jsonNum(params, "pollId"),
+			Question: jsonStr(obj, "title"),
+		}
+
+	case "chat.todo":
+		item, _ := params["item"].(map[string]any)
+		todo := TodoContent{
+			Content: jsonStr(item, "content"),
+			DueDate: jsonNum(item, "dueDate"),
+		}
+		if list, ok := item["assignees"].([]any); ok {
+			for _, v := range list {
+				if id := jsonString(v); id != "" {
+					todo.Assignees = append(todo.Assignees, id)
+				}
+			}
+		}
+		return todo
+	}
+
+	return UnknownContent{MsgType: m.MsgType, Content: m.Content}
+}
+
+func decodeLink(obj map[string]any) LinkContent {
+	return LinkContent{
+		URL:         jsonStr(obj, "href"),
+		Title:       jsonStr(obj, "title"),
+		Description: jsonStr(obj, "description"),
+		ThumbURL:    jsonStr(obj, "thumb"),
+	}
+}
+
+// object returns the content as a JSON object, nil for string and deleted contents.
+func (c Content) object() map[string]any {
+	switch {
+	case c.Attachment != nil:
+		raw, err := json.Marshal(c.Attachment)
+		if err != nil {
+			return nil
+		}
+		var obj map[string]any
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return nil
+		}
+		return obj
+	case c.Other != nil:
+		return c.Other
+	}
+	return nil
+}
+
+// contentParams returns the params of a content object, sent either as an
+// encoded JSON string or as an object.
+func contentParams(obj map[string]any) map[string]any {
+	params := map[string]any{}
+	switch v := obj["params"].(type) {
+	case string:
+		_ = json.Unmarshal([]byte(v), &params)
+	case map[string]any:
+		params = v
+	}
+	return params
+}
+
+func jsonString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
+
+func jsonStr(m map[string]any, keys ...string) string {
+	for _, k := range keys {
+		if s := jsonString(m[k]); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func jsonFloat(m map[string]any, keys ...string) float64 {
+	for _, k := range keys {
+		switch v := m[k].(type) {
+		case float64:
+			return v
+		case string:
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f
+			}
+		}
+	}
+	return 0
+}
+
+func jsonNum(m map[string]any, keys ...string) int64 {
+	for _, k := range keys {
+		if s, ok := m[k].(string); ok {
+			// IDs sent as strings may not fit in a float64
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return n
+			}
+		}
+		if f := jsonFloat(m, k); f != 0 {
+			return int64(f)
+		}
+	}
+	return 0
+}
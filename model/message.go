@@ -27,6 +27,8 @@ type UserMessage struct {
 	Data     TMessage
 	threadID string
 	isSelf   bool
+
+	Recovered bool // Fetched after a reconnect rather than received live
 }
 
 func NewUserMessage(uid string, data TMessage) UserMessage {
@@ -59,6 +61,8 @@ type GroupMessage struct {
 	Data     TGroupMessage
 	threadID string
 	isSelf   bool
+
+	Recovered bool // Fetched after a reconnect rather than received live
 }
 
 func NewGroupMessage(uid string, data TGroupMessage) GroupMessage {
@@ -130,12 +134,17 @@ type PropertyExt struct {
 	Type    int    `json:"type"`
 	SubType int    `json:"subType"`
 	Ext     string `json:"ext"`
+
+	TextProperties json.RawMessage `json:"textProperties,omitempty"` // Raw styles, see TMessage.TextStyles
 }
 
 type ParamsExt struct {
 	CountUnread  int `json:"countUnread"`
 	ContainType  int `json:"containType"`
 	PlatformType int `json:"platformType"`
+
+	TextProperties json.RawMessage `json:"textProperties,omitempty"` // Raw styles, see TMessage.TextStyles
+	Mentions       json.RawMessage `json:"mentions,omitempty"`       // Raw mentions, see TMessage.TextMentions
 }
 
 type TQuote struct {
@@ -135,6 +135,8 @@ type Reaction struct {
 	Data     TReaction
 	ThreadID string
 	IsSelf   bool
+
+	Recovered bool // Fetched after a reconnect rather than received live
 }
 
 func NewReaction(uid string, data TReaction, threadType ThreadType) Reaction {
@@ -0,0 +1,163 @@
+package model
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// TextStyles returns the styles of a text message. They are read from the
+// textProperties carried by PropertyExt or ParamsExt, or from the params of a
+// rich text content. Positions are in UTF-16 code units, as for sending.
+func (m TMessage) TextStyles() []MessageStyle {
+	var ext map[string]any
+	if m.PropertyExt != nil {
+		ext = rawObject(json.RawMessage(m.PropertyExt.Ext))
+	}
+
+	sources := []map[string]any{
+		rawObject(m.ParamsExt.TextProperties),
+		contentParams(m.Content.object()),
+	}
+	if m.PropertyExt != nil {
+		sources = append(sources, rawObject(m.PropertyExt.TextProperties), anyObject(ext["textProperties"]))
+	}
+
+	for _, props := range sources {
+		if styles := parseTextStyles(props); len(styles) > 0 {
+			return styles
+		}
+	}
+	return nil
+}
+
+// TextMentions returns the mentions carried by ParamsExt or PropertyExt.
+// Mentions of group messages are also available in TGroupMessage.Mentions.
+func (m TMessage) TextMentions() []TMention {
+	raws := []any{m.ParamsExt.Mentions}
+	if m.PropertyExt != nil {
+		ext := rawObject(json.RawMessage(m.PropertyExt.Ext))
+		raws = append(raws, ext["mentions"], ext["mentionInfo"])
+	}
+
+	for _, raw := range raws {
+		if mentions := parseMentions(raw); len(mentions) > 0 {
+			return mentions
+		}
+	}
+	return nil
+}
+
+// parseTextStyles reads styles formatted like the textProperties sent by
+// SendMessage. Combined styles ("b,i") are split into one style each.
+func parseTextStyles(props map[string]any) []MessageStyle {
+	list, _ := props["styles"].([]any)
+
+	var styles []MessageStyle
+	for _, v := range list {
+		s, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+		start, length := int(jsonNum(s, "start")), int(jsonNum(s, "len"))
+		for _, st := range strings.Split(jsonStr(s, "st"), ",") {
+			st = strings.TrimSpace(st)
+			if st == "" || length <= 0 {
+				continue
+			}
+
+			style := MessageStyle{Start: start, Len: length, Style: TextStyle(st)}
+			if size, ok := strings.CutPrefix(st, "ind_"); ok {
+				n, _ := strconv.Atoi(size)
+				style.Style = TextStyleIndent
+				style.IndentSize = max(n/10, 1)
+			}
+			styles = append(styles, style)
+		}
+	}
+	return styles
+}
+
+// parseMentions reads a mention list sent either as JSON or as an encoded JSON string.
+func parseMentions(raw any) []TMention {
+	var data []byte
+	switch v := raw.(type) {
+	case json.RawMessage:
+		data = v
+		var s string
+		if json.Unmarshal(v, &s) == nil {
+			data = []byte(s)
+		}
+	case string:
+		data = []byte(v)
+	case []any:
+		data, _ = json.Marshal(v)
+	default:
+		return nil
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	var list []struct {
+		UID  any         `json:"uid"`
+		Pos  int         `json:"pos"`
+		Len  int         `json:"len"`
+		Type MentionType `json:"type"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil
+	}
+
+	mentions := make([]TMention, 0, len(list))
+	for _, m := range list {
+		mention := TMention{UID: jsonString(m.UID), Pos: m.Pos, Len: m.Len, Type: m.Type}
+		if mention.IsValid() {
+			mentions = append(mentions, mention)
+		}
+	}
+	return mentions
+}
+
+// mergeMentions combines group mentions with parsed ones, dropping duplicates.
+func mergeMentions(group []*TMention, parsed []TMention) []TMention {
+	var out []TMention
+	for _, m := range group {
+		if m != nil {
+			out = append(out, *m)
+		}
+	}
+	for _, m := range parsed {
+		if !slices.Contains(out, m) {
+			out = append(out, m)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b TMention) int { return a.Pos - b.Pos })
+	return out
+}
+
+// rawObject decodes a JSON object, possibly encoded as a JSON string.
+func rawObject(raw json.RawMessage) map[string]any {
+	if len(raw) == 0 {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil
+	}
+	return anyObject(v)
+}
+
+func anyObject(v any) map[string]any {
+	switch v := v.(type) {
+	case map[string]any:
+		return v
+	case string:
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(v), &obj); err == nil {
+			return obj
+		}
+	}
+	return nil
+}
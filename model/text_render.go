@@ -0,0 +1,326 @@
+package model
+
+import (
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+	"unicode/utf16"
+)
+
+// TextSpan is a run of text sharing the same inline styles and mention.
+type TextSpan struct {
+	Text    string
+	Start   int // Offset in UTF-16 code units
+	Styles  []TextStyle
+	Mention *TMention
+}
+
+// Spans splits the text at every style and mention boundary. Line level styles
+// (lists and indent) are left out, use Markdown or HTML to render them.
+func (t TextContent) Spans() []TextSpan {
+	units := utf16.Encode([]rune(t.Text))
+	return buildSpans(units, 0, len(units), t.Styles, t.Mentions)
+}
+
+// Markdown renders the text as Markdown. Bold, italic and strike-through map to
+// their Markdown markers, underline to <u> and lists to list items. Colors,
+// sizes and indentation have no Markdown equivalent and are dropped.
+func (t TextContent) Markdown() string {
+	var b strings.Builder
+
+	number := 0
+	for i, line := range t.lines() {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+
+		switch line.list {
+		case TextStyleUnorderedList:
+			b.WriteString("- ")
+			number = 0
+		case TextStyleOrderedList:
+			number++
+			fmt.Fprintf(&b, "%d. ", number)
+		default:
+			number = 0
+		}
+
+		var content strings.Builder
+		for _, span := range line.spans {
+			writeMarkdownSpan(&content, span)
+		}
+		b.WriteString(escapeMarkdownLineStart(content.String()))
+	}
+
+	return b.String()
+}
+
+// HTML renders the text as an HTML fragment. Mentions are wrapped in a span
+// carrying the mentioned user ID in data-mention-uid.
+func (t TextContent) HTML() string {
+	var b strings.Builder
+
+	var list TextStyle
+	closeList := func() {
+		switch list {
+		case TextStyleUnorderedList:
+			b.WriteString("</ul>")
+		case TextStyleOrderedList:
+			b.WriteString("</ol>")
+		}
+		list = ""
+	}
+
+	lines := t.lines()
+	for i, line := range lines {
+		if line.list != list {
+			closeList()
+			switch line.list {
+			case TextStyleUnorderedList:
+				b.WriteString("<ul>")
+			case TextStyleOrderedList:
+				b.WriteString("<ol>")
+			}
+			list = line.list
+		}
+
+		switch {
+		case list != "":
+			b.WriteString("<li>")
+		case line.indent > 0:
+			fmt.Fprintf(&b, `<div style="padding-left:%dpx">`, line.indent*40)
+		}
+
+		for _, span := range line.spans {
+			writeHTMLSpan(&b, span)
+		}
+
+		switch {
+		case list != "":
+			b.WriteString("</li>")
+		case line.indent > 0:
+			b.WriteString("</div>")
+		case i < len(lines)-1 && lines[i+1].list == "" && lines[i+1].indent == 0:
+			b.WriteString("<br>")
+		}
+	}
+	closeList()
+
+	return b.String()
+}
+
+type textLine struct {
+	spans  []TextSpan
+	list   TextStyle // TextStyleOrderedList, TextStyleUnorderedList or empty
+	indent int
+}
+
+func (t TextContent) lines() []textLine {
+	units := utf16.Encode([]rune(t.Text))
+
+	var lines []textLine
+	start := 0
+	for i := 0; i <= len(units); i++ {
+		if i < len(units) && units[i] != '\n' {
+			continue
+		}
+
+		line := textLine{spans: buildSpans(units, start, i, t.Styles, t.Mentions)}
+		for _, s := range t.Styles {
+			if start < s.Start || start >= s.Start+s.Len {
+				continue
+			}
+			switch s.Style {
+			case TextStyleOrderedList, TextStyleUnorderedList:
+				line.list = s.Style
+			case TextStyleIndent:
+				line.indent = max(s.IndentSize, 1)
+			}
+		}
+		lines = append(lines, line)
+		start = i + 1
+	}
+	return lines
+}
+
+func isInlineStyle(st TextStyle) bool {
+	switch st {
+	case TextStyleOrderedList, TextStyleUnorderedList, TextStyleIndent:
+		return false
+	}
+	return true
+}
+
+func buildSpans(units []uint16, from, to int, styles []MessageStyle, mentions []TMention) []TextSpan {
+	if from >= to {
+		return nil
+	}
+
+	cuts := []int{from, to}
+	addCut := func(pos int) {
+		if pos > from && pos < to {
+			cuts = append(cuts, pos)
+		}
+	}
+	for _, s := range styles {
+		if isInlineStyle(s.Style) {
+			addCut(s.Start)
+			addCut(s.Start + s.Len)
+		}
+	}
+	for _, m := range mentions {
+		addCut(m.Pos)
+		addCut(m.Pos + m.Len)
+	}
+	slices.Sort(cuts)
+	cuts = slices.Compact(cuts)
+
+	spans := make([]TextSpan, 0, len(cuts)-1)
+	for i := 0; i < len(cuts)-1; i++ {
+		a, b := cuts[i], cuts[i+1]
+		span := TextSpan{Text: string(utf16.Decode(units[a:b])), Start: a}
+
+		for _, s := range styles {
+			if isInlineStyle(s.Style) && s.Start <= a && b <= s.Start+s.Len && !slices.Contains(span.Styles, s.Style) {
+				span.Styles = append(span.Styles, s.Style)
+			}
+		}
+		for _, m := range mentions {
+			if m.Pos <= a && b <= m.Pos+m.Len {
+				span.Mention = &m
+				break
+			}
+		}
+
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `~`, `\~`,
+	`[`, `\[`, `]`, `\]`, `<`, `\<`, `>`, `\>`, `#`, `\#`,
+)
+
+// escapeMarkdownLineStart escapes the list marker a line starts with ("-", "+"
+// or a number followed by "." or ")"), so it is not read as a list item.
+func escapeMarkdownLineStart(line string) string {
+	rest := strings.TrimLeft(line, " \t")
+	lead := line[:len(line)-len(rest)]
+
+	if strings.HasPrefix(rest, "-") || strings.HasPrefix(rest, "+") {
+		return lead + `\` + rest
+	}
+
+	digits := len(rest) - len(strings.TrimLeft(rest, "0123456789"))
+	if digits > 0 && digits < len(rest) && (rest[digits] == '.' || rest[digits] == ')') {
+		return lead + rest[:digits] + `\` + rest[digits:]
+	}
+	return line
+}
+
+func writeMarkdownSpan(b *strings.Builder, span TextSpan) {
+	// Markers must hug the text, so surrounding spaces are moved outside
+	text := markdownEscaper.Replace(span.Text)
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		b.WriteString(text)
+		return
+	}
+	lead := text[:strings.Index(text, trimmed)]
+	trail := text[len(lead)+len(trimmed):]
+
+	var open, closing []string
+	for _, st := range span.Styles {
+		var o, c string
+		switch st {
+		case TextStyleBold:
+			o, c = "**", "**"
+		case TextStyleItalic:
+			o, c = "_", "_"
+		case TextStyleStrikeThrough:
+			o, c = "~~", "~~"
+		case TextStyleUnderline:
+			o, c = "<u>", "</u>"
+		default:
+			continue
+		}
+		open = append(open, o)
+		closing = append([]string{c}, closing...)
+	}
+
+	b.WriteString(lead)
+	b.WriteString(strings.Join(open, ""))
+	b.WriteString(trimmed)
+	b.WriteString(strings.Join(closing, ""))
+	b.WriteString(trail)
+}
+
+func writeHTMLSpan(b *strings.Builder, span TextSpan) {
+	var open, closing []string
+	for _, st := range span.Styles {
+		var o, c string
+		switch {
+		case st == TextStyleBold:
+			o, c = "<b>", "</b>"
+		case st == TextStyleItalic:
+			o, c = "<i>", "</i>"
+		case st == TextStyleUnderline:
+			o, c = "<u>", "</u>"
+		case st == TextStyleStrikeThrough:
+			o, c = "<s>", "</s>"
+		case strings.HasPrefix(string(st), "c_"):
+			// Values are written into the style attribute, anything but a hex color is dropped
+			color := string(st[2:])
+			if !isHexColor(color) {
+				continue
+			}
+			o, c = fmt.Sprintf(`<span style="color:#%s">`, color), "</span>"
+		case strings.HasPrefix(string(st), "f_"):
+			size := string(st[2:])
+			if !isDigits(size) {
+				continue
+			}
+			o, c = fmt.Sprintf(`<span style="font-size:%spx">`, size), "</span>"
+		default:
+			continue
+		}
+		open = append(open, o)
+		closing = append([]string{c}, closing...)
+	}
+	if span.Mention != nil {
+		open = append([]string{fmt.Sprintf(`<span data-mention-uid="%s">`, html.EscapeString(span.Mention.UID))}, open...)
+		closing = append(closing, "</span>")
+	}
+
+	b.WriteString(strings.Join(open, ""))
+	b.WriteString(html.EscapeString(span.Text))
+	b.WriteString(strings.Join(closing, ""))
+}
+
+// isHexColor reports whether s is made of 3 to 8 hex digits.
+func isHexColor(s string) bool {
+	if len(s) < 3 || len(s) > 8 {
+		return false
+	}
+	for i := range len(s) {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := range len(s) {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
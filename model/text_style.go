@@ -0,0 +1,31 @@
+package model
+
+type TextStyle string
+
+const (
+	TextStyleBold          TextStyle = "b"
+	TextStyleItalic        TextStyle = "i"
+	TextStyleUnderline     TextStyle = "u"
+	TextStyleStrikeThrough TextStyle = "s"
+
+	TextStyleRed    TextStyle = "c_db342e"
+	TextStyleOrange TextStyle = "c_f27806"
+	TextStyleYellow TextStyle = "c_f7b503"
+	TextStyleGreen  TextStyle = "c_15a85f"
+
+	TextStyleSmall TextStyle = "f_13"
+	TextStyleBig   TextStyle = "f_18"
+
+	TextStyleOrderedList   TextStyle = "lst_2"
+	TextStyleUnorderedList TextStyle = "lst_1"
+
+	TextStyleIndent TextStyle = "ind_$"
+)
+
+type MessageStyle struct {
+	Start int       `json:"start"`
+	Len   int       `json:"len"`
+	Style TextStyle `json:"st"`
+
+	IndentSize int `json:"indentSize"` // Used for indent style
+}
@@ -3,6 +3,7 @@ package zcago
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/Amrakk/zcago/session"
 	"github.com/Amrakk/zcago/session/auth"
@@ -50,6 +51,9 @@ func WithHTTPClient(c *http.Client) session.Option { return session.WithHTTPClie
 func WithAPIType(t uint) session.Option            { return session.WithAPIType(t) }
 func WithAPIVersion(v uint) session.Option         { return session.WithAPIVersion(v) }
 
+// WithSendInterval sets the minimum delay between two messages sent by the session.
+func WithSendInterval(d time.Duration) session.Option { return session.WithSendInterval(d) }
+
 func WithImageMetadataGetter(f session.ImageMetadataGetter) session.Option {
 	return session.WithImageMetadataGetter(f)
 }
@@ -0,0 +1,159 @@
+package outbox
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/Amrakk/zcago/errs"
+)
+
+type journalOp string
+
+const (
+	opAdd  journalOp = "add"
+	opDone journalOp = "done"
+	opFail journalOp = "fail"
+)
+
+type journalRecord struct {
+	Op    journalOp `json:"op"`
+	Entry *Entry    `json:"entry,omitempty"`
+	ID    string    `json:"id,omitempty"`
+	MsgID string    `json:"msgId,omitempty"`
+	Error string    `json:"error,omitempty"`
+}
+
+// journal is an append-only JSON lines log of outbox operations.
+// It is compacted on open so only pending entries are carried over.
+type journal struct {
+	f *os.File
+}
+
+func openJournal(path string) (*journal, []Entry, error) {
+	pending, err := replayJournal(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := compactJournal(path, pending); err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, nil, errs.WrapZCA("failed to open journal", "outbox.openJournal", err)
+	}
+
+	return &journal{f: f}, pending, nil
+}
+
+func replayJournal(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errs.WrapZCA("failed to read journal", "outbox.replayJournal", err)
+	}
+	defer f.Close()
+
+	var (
+		order   []string
+		entries = make(map[string]Entry)
+	)
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		var rec journalRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			// A torn last line from a crash mid-write is ignored
+			continue
+		}
+
+		switch rec.Op {
+		case opAdd:
+			if rec.Entry == nil {
+				continue
+			}
+			if _, ok := entries[rec.Entry.ID]; !ok {
+				order = append(order, rec.Entry.ID)
+			}
+			entries[rec.Entry.ID] = *rec.Entry
+		case opDone, opFail:
+			delete(entries, rec.ID)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errs.WrapZCA("failed to read journal", "outbox.replayJournal", err)
+	}
+
+	pending := make([]Entry, 0, len(entries))
+	for _, id := range order {
+		if e, ok := entries[id]; ok {
+			pending = append(pending, e)
+		}
+	}
+	slices.SortStableFunc(pending, func(a, b Entry) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return pending, nil
+}
+
+func compactJournal(path string, pending []Entry) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return errs.WrapZCA("failed to create temp journal", "outbox.compactJournal", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for i := range pending {
+		if err := enc.Encode(journalRecord{Op: opAdd, Entry: &pending[i]}); err != nil {
+			_ = tmp.Close()
+			return errs.WrapZCA("failed to write journal", "outbox.compactJournal", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		return errs.WrapZCA("failed to write journal", "outbox.compactJournal", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return errs.WrapZCA("failed to sync journal", "outbox.compactJournal", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return errs.WrapZCA("failed to write journal", "outbox.compactJournal", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errs.WrapZCA("failed to replace journal", "outbox.compactJournal", err)
+	}
+	return nil
+}
+
+// append writes rec and syncs it to disk before returning.
+func (j *journal) append(rec journalRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return errs.WrapZCA("failed to encode journal record", "outbox.journal", err)
+	}
+	data = append(data, '\n')
+
+	if _, err := j.f.Write(data); err != nil {
+		return errs.WrapZCA("failed to write journal", "outbox.journal", err)
+	}
+	if err := j.f.Sync(); err != nil {
+		return errs.WrapZCA("failed to sync journal", "outbox.journal", err)
+	}
+	return nil
+}
+
+func (j *journal) close() error {
+	return j.f.Close()
+}
@@ -0,0 +1,350 @@
+// Package outbox provides a durable queue for outgoing messages.
+//
+// Every message is journaled to disk before it is sent and marked done once
+// Zalo returns a msgId. Entries still pending after a crash are replayed on the
+// next Open with the clientId generated for the first attempt, so a message that
+// did go out before the crash is not duplicated.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Amrakk/zcago/api"
+	"github.com/Amrakk/zcago/errs"
+	"github.com/Amrakk/zcago/model"
+)
+
+var (
+	ErrAlreadyStarted = errs.NewZCA("outbox already started", "outbox.Start")
+	ErrClosed         = errs.NewZCA("outbox is closed", "outbox.Send")
+)
+
+// Sender is the subset of zcago.API used to deliver entries.
+type Sender interface {
+	SendMessage(ctx context.Context, threadID string, threadType model.ThreadType, message api.MessageContent) (*api.SendMessageResponse, error)
+}
+
+// Message is the journaled subset of api.MessageContent.
+// Attachments are not supported since an upload cannot be replayed idempotently.
+type Message struct {
+	Msg      string                `json:"msg"`
+	Style    []api.MessageStyle    `json:"style,omitempty"`
+	Urgency  model.Urgency         `json:"urgency,omitempty"`
+	Quote    *api.SendMessageQuote `json:"quote,omitempty"`
+	Mentions []model.TMention      `json:"mentions,omitempty"`
+	TTL      int                   `json:"ttl,omitempty"`
+}
+
+type Entry struct {
+	ID         string           `json:"id"`
+	ClientID   int64            `json:"clientId"`
+	ThreadID   string           `json:"threadId"`
+	ThreadType model.ThreadType `json:"threadType"`
+	Message    Message          `json:"message"`
+	CreatedAt  time.Time        `json:"createdAt"`
+}
+
+type Result struct {
+	Entry    Entry
+	MsgID    string
+	Attempts int
+	Err      error // Set when the entry failed permanently or ran out of attempts
+}
+
+type Options struct {
+	MaxAttempts  int           // Attempts per entry before it is marked failed, defaults to 5; negative means unlimited
+	BaseDelay    time.Duration // First retry delay, doubled on each attempt, defaults to 1s
+	MaxDelay     time.Duration // Upper bound of the retry delay, defaults to 1m
+	ResultBuffer int           // Buffer size of the Results channel, defaults to 16
+
+	// IsTransient reports whether a failed send should be retried, defaults to IsTransient.
+	IsTransient func(err error) bool
+}
+
+type Outbox struct {
+	mu sync.Mutex
+
+	sender  Sender
+	opts    Options
+	journal *journal
+
+	queue        []Entry
+	lastClientID int64
+	wake         chan struct{}
+	results      chan Result
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// Open loads the journal at path, creating it if missing. Entries left pending
+// by a previous run are queued again and sent once Start is called.
+func Open(path string, sender Sender, opts *Options) (*Outbox, error) {
+	o := Options{
+		MaxAttempts:  5,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		ResultBuffer: 16,
+		IsTransient:  IsTransient,
+	}
+	if opts != nil {
+		if opts.MaxAttempts != 0 {
+			o.MaxAttempts = opts.MaxAttempts
+		}
+		if opts.BaseDelay > 0 {
+			o.BaseDelay = opts.BaseDelay
+		}
+		if opts.MaxDelay > 0 {
+			o.MaxDelay = opts.MaxDelay
+		}
+		if opts.ResultBuffer > 0 {
+			o.ResultBuffer = opts.ResultBuffer
+		}
+		if opts.IsTransient != nil {
+			o.IsTransient = opts.IsTransient
+		}
+	}
+
+	j, pending, err := openJournal(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var last int64
+	for _, e := range pending {
+		last = max(last, e.ClientID)
+	}
+
+	return &Outbox{
+		sender:       sender,
+		opts:         o,
+		journal:      j,
+		queue:        pending,
+		lastClientID: last,
+		wake:         make(chan struct{}, 1),
+		results:      make(chan Result, o.ResultBuffer),
+	}, nil
+}
+
+// Results delivers the outcome of every entry once it is done or failed.
+func (o *Outbox) Results() <-chan Result { return o.results }
+
+// Start begins delivering queued entries in order until ctx is done or Close is called.
+func (o *Outbox) Start(ctx context.Context) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return ErrClosed
+	}
+	if o.cancel != nil {
+		return ErrAlreadyStarted
+	}
+
+	wctx, cancel := context.WithCancel(ctx)
+	o.cancel = cancel
+
+	o.wg.Add(1)
+	go o.run(wctx)
+
+	return nil
+}
+
+// Send journals the message and queues it for delivery.
+// The returned entry is durable once Send returns without error.
+func (o *Outbox) Send(threadID string, threadType model.ThreadType, message Message) (Entry, error) {
+	if message.Msg == "" {
+		return Entry{}, api.ErrMessageContentEmpty
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return Entry{}, ErrClosed
+	}
+
+	clientID := time.Now().UnixMilli()
+	if clientID <= o.lastClientID {
+		clientID = o.lastClientID + 1
+	}
+
+	e := Entry{
+		ID:         uuid.NewString(),
+		ClientID:   clientID,
+		ThreadID:   threadID,
+		ThreadType: threadType,
+		Message:    message,
+		CreatedAt:  time.Now(),
+	}
+	if err := o.journal.append(journalRecord{Op: opAdd, Entry: &e}); err != nil {
+		return Entry{}, err
+	}
+	o.lastClientID = clientID
+
+	o.queue = append(o.queue, e)
+	o.signal()
+
+	return e, nil
+}
+
+// Pending lists the entries not yet done or failed, in delivery order.
+func (o *Outbox) Pending() []Entry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]Entry(nil), o.queue...)
+}
+
+// Close stops delivery and closes the journal. Undelivered entries are kept
+// in the journal and replayed by the next Open.
+func (o *Outbox) Close() error {
+	o.mu.Lock()
+	if o.closed {
+		o.mu.Unlock()
+		return nil
+	}
+	o.closed = true
+	cancel := o.cancel
+	o.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	o.wg.Wait()
+
+	return o.journal.close()
+}
+
+func (o *Outbox) run(ctx context.Context) {
+	defer o.wg.Done()
+
+	for {
+		o.mu.Lock()
+		var (
+			e  Entry
+			ok = len(o.queue) > 0
+		)
+		if ok {
+			e = o.queue[0]
+		}
+		o.mu.Unlock()
+
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-o.wake:
+				continue
+			}
+		}
+
+		res, finished := o.deliver(ctx, e)
+		if !finished {
+			// Aborted by shutdown, the entry stays queued and journaled
+			return
+		}
+
+		rec := journalRecord{Op: opDone, ID: e.ID, MsgID: res.MsgID}
+		if res.Err != nil {
+			rec = journalRecord{Op: opFail, ID: e.ID, Error: res.Err.Error()}
+		}
+
+		o.mu.Lock()
+		if err := o.journal.append(rec); err != nil && res.Err == nil {
+			res.Err = errs.WrapZCA("message sent but could not be marked done", "outbox.run", err)
+		}
+		o.queue = o.queue[1:]
+		o.mu.Unlock()
+
+		select {
+		case o.results <- res:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver sends e, retrying transient errors with exponential backoff.
+// It reports finished=false when ctx was cancelled before an outcome was known.
+func (o *Outbox) deliver(ctx context.Context, e Entry) (Result, bool) {
+	res := Result{Entry: e}
+	content := e.Message.toContent(e.ClientID)
+	delay := o.opts.BaseDelay
+
+	for {
+		res.Attempts++
+		resp, err := o.sender.SendMessage(ctx, e.ThreadID, e.ThreadType, content)
+		if err == nil {
+			res.MsgID = resp.MsgID()
+			return res, true
+		}
+		if ctx.Err() != nil {
+			return res, false
+		}
+
+		exhausted := o.opts.MaxAttempts > 0 && res.Attempts >= o.opts.MaxAttempts
+		if exhausted || !o.opts.IsTransient(err) {
+			res.Err = err
+			return res, true
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return res, false
+		case <-t.C:
+		}
+		delay = min(delay*2, o.opts.MaxDelay)
+	}
+}
+
+func (o *Outbox) signal() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m Message) toContent(clientID int64) api.MessageContent {
+	return api.MessageContent{
+		Msg:      m.Msg,
+		Style:    m.Style,
+		Urgency:  m.Urgency,
+		Quote:    m.Quote,
+		Mentions: m.Mentions,
+		TTL:      m.TTL,
+		ClientID: clientID,
+	}
+}
+
+// IsTransient reports whether err is worth retrying: network failures and
+// HTTP 429/5xx responses are, while errors returned by Zalo for the request
+// itself and local validation errors are not.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var zerr errs.ZaloAPIError
+	if errors.As(err, &zerr) {
+		if zerr.Code == nil {
+			return true
+		}
+		code := int(*zerr.Code)
+		return code == 429 || (code >= 500 && code < 600)
+	}
+
+	var zca errs.ZCAError
+	if errors.As(err, &zca) && zca.Cause == nil {
+		return false
+	}
+
+	return true
+}
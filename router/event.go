@@ -0,0 +1,31 @@
+package router
+
+import "github.com/Amrakk/zcago/listener"
+
+type (
+	// Kind names the listener channel an event was read from.
+	Kind = listener.EventKind
+	// Event is a value read from the listener, see listener.Event.
+	Event = listener.Event
+)
+
+const (
+	KindConnected         = listener.KindConnected
+	KindDisconnected      = listener.KindDisconnected
+	KindClosed            = listener.KindClosed
+	KindError             = listener.KindError
+	KindMessage           = listener.KindMessage
+	KindOldMessages       = listener.KindOldMessages
+	KindReaction          = listener.KindReaction
+	KindOldReactions      = listener.KindOldReactions
+	KindTyping            = listener.KindTyping
+	KindDeliveredMessages = listener.KindDeliveredMessages
+	KindSeenMessages      = listener.KindSeenMessages
+	KindUndo              = listener.KindUndo
+	KindUploadAttachment  = listener.KindUploadAttachment
+	KindFriend            = listener.KindFriend
+	KindGroup             = listener.KindGroup
+	KindCipherKey         = listener.KindCipherKey
+	KindRaw               = listener.KindRaw
+	KindState             = listener.KindState
+)
@@ -0,0 +1,77 @@
+package router
+
+import (
+	"regexp"
+	"slices"
+
+	"github.com/Amrakk/zcago/model"
+)
+
+// Filter decides whether a handler receives an event. A handler registered
+// with several filters only receives the events matching all of them.
+type Filter func(ev Event) bool
+
+// InThread matches events of any of the given threads.
+func InThread(threadIDs ...string) Filter {
+	return func(ev Event) bool {
+		id, _, ok := ev.Thread()
+		return ok && slices.Contains(threadIDs, id)
+	}
+}
+
+// OfThreadType matches events of user or group threads.
+func OfThreadType(threadType model.ThreadType) Filter {
+	return func(ev Event) bool {
+		_, typ, ok := ev.Thread()
+		return ok && typ == threadType
+	}
+}
+
+// FromSelf matches events triggered by the logged in account.
+func FromSelf() Filter { return Event.IsSelf }
+
+// NotFromSelf matches events triggered by anyone but the logged in account.
+func NotFromSelf() Filter { return Not(FromSelf()) }
+
+// OfMessageType matches messages whose msgType is one of the given ones,
+// e.g. "webchat" or "chat.photo".
+func OfMessageType(msgTypes ...string) Filter {
+	return func(ev Event) bool {
+		msg, ok := ev.Message()
+		return ok && slices.Contains(msgTypes, msg.MsgType)
+	}
+}
+
+// TextMatches matches text messages whose content matches re.
+func TextMatches(re *regexp.Regexp) Filter {
+	return func(ev Event) bool {
+		msg, ok := ev.Message()
+		return ok && msg.Content.String != nil && re.MatchString(*msg.Content.String)
+	}
+}
+
+// Not inverts f.
+func Not(f Filter) Filter {
+	return func(ev Event) bool { return !f(ev) }
+}
+
+// AnyOf matches events matching at least one of filters.
+func AnyOf(filters ...Filter) Filter {
+	return func(ev Event) bool {
+		for _, f := range filters {
+			if f(ev) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func matchAll(filters []Filter, ev Event) bool {
+	for _, f := range filters {
+		if !f(ev) {
+			return false
+		}
+	}
+	return true
+}
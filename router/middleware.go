@@ -0,0 +1,56 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// Middleware wraps a handler, e.g. to log, time or guard its execution.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// PanicError is returned by handlers wrapped with Recover when they panic.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e PanicError) Error() string { return fmt.Sprintf("router: handler panicked: %v", e.Value) }
+
+// Recover turns handler panics into a PanicError.
+func Recover() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, ev Event) (err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					err = PanicError{Value: v, Stack: debug.Stack()}
+				}
+			}()
+			return next(ctx, ev)
+		}
+	}
+}
+
+// Timing reports how long each handler call took along with its result.
+func Timing(report func(ev Event, elapsed time.Duration, err error)) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, ev Event) error {
+			start := time.Now()
+			err := next(ctx, ev)
+			report(ev, time.Since(start), err)
+			return err
+		}
+	}
+}
+
+// Timeout cancels the context of handler calls running longer than d.
+func Timeout(d time.Duration) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, ev Event) error {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next(ctx, ev)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+// Package router dispatches listener events to registered handlers, running them
+// concurrently on a pool of workers instead of a hand-written select loop.
+package router
+
+import (
+	"context"
+	"log"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/Amrakk/zcago/errs"
+	"github.com/Amrakk/zcago/listener"
+	"github.com/Amrakk/zcago/model"
+)
+
+var errUnexpectedValue = errs.NewZCA("event value does not match its kind", "router.Handle")
+
+// HandlerFunc handles an event. Returned errors are passed to Options.OnError.
+type HandlerFunc func(ctx context.Context, ev Event) error
+
+type Options struct {
+	Workers   int // Maximum number of handlers running at the same time, defaults to runtime.NumCPU
+	QueueSize int // Handler calls waiting for a worker before reading the listener pauses, defaults to 256
+
+	// OnError receives the errors returned by handlers, logged with the log package by default.
+	OnError func(ev Event, err error)
+}
+
+type route struct {
+	kind    Kind
+	filters []Filter
+	handler HandlerFunc
+}
+
+type job struct {
+	ev      Event
+	handler HandlerFunc
+}
+
+type Router struct {
+	mu sync.RWMutex
+
+	ln         listener.Listener
+	opts       Options
+	routes     []route
+	middleware []Middleware
+}
+
+func New(ln listener.Listener, opts *Options) *Router {
+	r := &Router{ln: ln}
+	if opts != nil {
+		r.opts = *opts
+	}
+	if r.opts.Workers <= 0 {
+		r.opts.Workers = runtime.NumCPU()
+	}
+	if r.opts.QueueSize <= 0 {
+		r.opts.QueueSize = 256
+	}
+	if r.opts.OnError == nil {
+		r.opts.OnError = func(ev Event, err error) {
+			log.Printf("router: %s handler failed: %v", ev.Kind, err)
+		}
+	}
+	return r
+}
+
+// Use appends middleware to the chain wrapping every handler. The first
+// middleware added is the outermost one.
+func (r *Router) Use(mw ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middleware = append(r.middleware, mw...)
+}
+
+// Handle registers h for the events of kind matching all filters.
+func (r *Router) Handle(kind Kind, h HandlerFunc, filters ...Filter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes = append(r.routes, route{kind: kind, filters: filters, handler: h})
+}
+
+func (r *Router) OnMessage(fn func(ctx context.Context, msg model.Message) error, filters ...Filter) {
+	r.Handle(KindMessage, typed(fn), filters...)
+}
+
+func (r *Router) OnOldMessages(fn func(ctx context.Context, msgs model.OldMessages) error, filters ...Filter) {
+	r.Handle(KindOldMessages, typed(fn), filters...)
+}
+
+func (r *Router) OnReaction(fn func(ctx context.Context, reaction model.Reaction) error, filters ...Filter) {
+	r.Handle(KindReaction, typed(fn), filters...)
+}
+
+func (r *Router) OnOldReactions(fn func(ctx context.Context, reactions model.OldReactions) error, filters ...Filter) {
+	r.Handle(KindOldReactions, typed(fn), filters...)
+}
+
+func (r *Router) OnTyping(fn func(ctx context.Context, typing model.Typing) error, filters ...Filter) {
+	r.Handle(KindTyping, typed(fn), filters...)
+}
+
+func (r *Router) OnDeliveredMessages(fn func(ctx context.Context, msgs []model.DeliveredMessage) error, filters ...Filter) {
+	r.Handle(KindDeliveredMessages, typed(fn), filters...)
+}
+
+func (r *Router) OnSeenMessages(fn func(ctx context.Context, msgs []model.SeenMessage) error, filters ...Filter) {
+	r.Handle(KindSeenMessages, typed(fn), filters...)
+}
+
+func (r *Router) OnUndo(fn func(ctx context.Context, undo model.Undo) error, filters ...Filter) {
+	r.Handle(KindUndo, typed(fn), filters...)
+}
+
+func (r *Router) OnUploadAttachment(fn func(ctx context.Context, upload model.UploadAttachment) error, filters ...Filter) {
+	r.Handle(KindUploadAttachment, typed(fn), filters...)
+}
+
+// OnGroupEvent registers fn for group events of the given type, or of every type when typ is empty.
+func (r *Router) OnGroupEvent(typ model.GroupEventType, fn func(ctx context.Context, ev model.GroupEvent) error, filters ...Filter) {
+	if typ != "" {
+		filters = append([]Filter{func(ev Event) bool {
+			g, ok := ev.Value.(model.GroupEvent)
+			return ok && g.Type() == typ
+		}}, filters...)
+	}
+	r.Handle(KindGroup, typed(fn), filters...)
+}
+
+// OnFriendEvent registers fn for friend events of the given type, or of every type when typ is empty.
+func (r *Router) OnFriendEvent(typ model.FriendEventType, fn func(ctx context.Context, ev model.FriendEvent) error, filters ...Filter) {
+	if typ != "" {
+		filters = append([]Filter{func(ev Event) bool {
+			f, ok := ev.Value.(model.FriendEvent)
+			return ok && f.Type() == typ
+		}}, filters...)
+	}
+	r.Handle(KindFriend, typed(fn), filters...)
+}
+
+func (r *Router) OnError(fn func(ctx context.Context, err error) error) {
+	r.Handle(KindError, typed(fn))
+}
+
+func (r *Router) OnConnected(fn func(ctx context.Context) error) {
+	r.Handle(KindConnected, func(ctx context.Context, _ Event) error { return fn(ctx) })
+}
+
+func (r *Router) OnDisconnected(fn func(ctx context.Context, ci listener.CloseInfo) error) {
+	r.Handle(KindDisconnected, typed(fn))
+}
+
+func (r *Router) OnClosed(fn func(ctx context.Context, ci listener.CloseInfo) error) {
+	r.Handle(KindClosed, typed(fn))
+}
+
+// Run reads the listener and dispatches its events until ctx is done, which
+// returns ctx.Err(), or the listener is closed, which returns nil. The listener
+// must be started separately. Run waits for the queued handler calls to finish
+// before returning.
+func (r *Router) Run(ctx context.Context) error {
+	jobs := make(chan job, r.opts.QueueSize)
+
+	var wg sync.WaitGroup
+	for range r.opts.Workers {
+		wg.Go(func() {
+			for j := range jobs {
+				r.call(ctx, j)
+			}
+		})
+	}
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
+	for {
+		ev, ok := r.next(ctx)
+		if !ok {
+			return ctx.Err()
+		}
+
+		for _, h := range r.match(ev) {
+			select {
+			case jobs <- job{ev: ev, handler: h}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if ev.Kind == KindClosed {
+			return nil
+		}
+	}
+}
+
+// match returns the handlers of the routes matching ev, wrapped in the middleware chain.
+func (r *Router) match(ev Event) []HandlerFunc {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var handlers []HandlerFunc
+	for _, rt := range r.routes {
+		if rt.kind != ev.Kind || !matchAll(rt.filters, ev) {
+			continue
+		}
+		h := rt.handler
+		for i := len(r.middleware) - 1; i >= 0; i-- {
+			h = r.middleware[i](h)
+		}
+		handlers = append(handlers, h)
+	}
+	return handlers
+}
+
+func (r *Router) call(ctx context.Context, j job) {
+	if err := j.handler(ctx, j.ev); err != nil {
+		r.opts.OnError(j.ev, err)
+	}
+}
+
+func (r *Router) next(ctx context.Context) (Event, bool) {
+	var (
+		kind  Kind
+		value any
+	)
+
+	ln := r.ln
+	select {
+	case <-ctx.Done():
+		return Event{}, false
+	case <-ln.Connected():
+		kind = KindConnected
+	case v := <-ln.Disconnected():
+		kind, value = KindDisconnected, v
+	case v := <-ln.Closed():
+		kind, value = KindClosed, v
+	case v := <-ln.Error():
+		kind, value = KindError, v
+	case v := <-ln.Message():
+		kind, value = KindMessage, v
+	case v := <-ln.OldMessages():
+		kind, value = KindOldMessages, v
+	case v := <-ln.Reaction():
+		kind, value = KindReaction, v
+	case v := <-ln.OldReactions():
+		kind, value = KindOldReactions, v
+	case v := <-ln.Typing():
+		kind, value = KindTyping, v
+	case v := <-ln.DeliveredMessages():
+		kind, value = KindDeliveredMessages, v
+	case v := <-ln.SeenMessages():
+		kind, value = KindSeenMessages, v
+	case v := <-ln.Undo():
+		kind, value = KindUndo, v
+	case v := <-ln.UploadAttachment():
+		kind, value = KindUploadAttachment, v
+	case v := <-ln.Friend():
+		kind, value = KindFriend, v
+	case v := <-ln.Group():
+		kind, value = KindGroup, v
+	case v := <-ln.CipherKey():
+		kind, value = KindCipherKey, v
+	}
+
+	return Event{Kind: kind, Value: value, Received: time.Now()}, true
+}
+
+// typed adapts a handler of a concrete event value to a HandlerFunc.
+func typed[T any](fn func(ctx context.Context, v T) error) HandlerFunc {
+	return func(ctx context.Context, ev Event) error {
+		v, ok := ev.Value.(T)
+		if !ok {
+			return errUnexpectedValue
+		}
+		return fn(ctx, v)
+	}
+}
@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/Amrakk/zcago/api"
+	"github.com/Amrakk/zcago/model"
+)
+
+type JobKind string
+
+const (
+	JobKindMessage JobKind = "message"
+	JobKindSticker JobKind = "sticker"
+	JobKindLink    JobKind = "link"
+)
+
+type Job struct {
+	ID         string           `json:"id"`
+	Kind       JobKind          `json:"kind"`
+	ThreadID   string           `json:"threadId"`
+	ThreadType model.ThreadType `json:"threadType"`
+	RunAt      time.Time        `json:"runAt"`
+	CreatedAt  time.Time        `json:"createdAt"`
+
+	Message *Message                `json:"message,omitempty"`
+	Sticker *api.SendStickerPayload `json:"sticker,omitempty"`
+	Link    *Link                   `json:"link,omitempty"`
+}
+
+// Message is the persistable subset of api.MessageContent.
+// Attachments are referenced by file path so the job survives a restart.
+type Message struct {
+	Msg         string                `json:"msg"`
+	Style       []api.MessageStyle    `json:"style,omitempty"`
+	Urgency     model.Urgency         `json:"urgency,omitempty"`
+	Quote       *api.SendMessageQuote `json:"quote,omitempty"`
+	Mentions    []model.TMention      `json:"mentions,omitempty"`
+	Attachments []string              `json:"attachments,omitempty"`
+	TTL         int                   `json:"ttl,omitempty"`
+}
+
+type Link struct {
+	Msg      string           `json:"msg"`
+	Link     string           `json:"link"`
+	TTL      int              `json:"ttl,omitempty"`
+	Mentions []model.TMention `json:"mentions,omitempty"`
+}
+
+type Result struct {
+	Job     Job
+	MsgID   string
+	Missed  bool // The job was due while the scheduler was not running
+	Skipped bool // The job was dropped according to the MissedPolicy
+	Err     error
+}
+
+func NewMessageJob(threadID string, threadType model.ThreadType, runAt time.Time, message Message) Job {
+	return Job{Kind: JobKindMessage, ThreadID: threadID, ThreadType: threadType, RunAt: runAt, Message: &message}
+}
+
+func NewStickerJob(threadID string, threadType model.ThreadType, runAt time.Time, sticker api.SendStickerPayload) Job {
+	return Job{Kind: JobKindSticker, ThreadID: threadID, ThreadType: threadType, RunAt: runAt, Sticker: &sticker}
+}
+
+func NewLinkJob(threadID string, threadType model.ThreadType, runAt time.Time, link Link) Job {
+	return Job{Kind: JobKindLink, ThreadID: threadID, ThreadType: threadType, RunAt: runAt, Link: &link}
+}
+
+func (j Job) isValid() bool {
+	if j.ThreadID == "" || j.RunAt.IsZero() {
+		return false
+	}
+
+	switch j.Kind {
+	case JobKindMessage:
+		return j.Message != nil
+	case JobKindSticker:
+		return j.Sticker != nil
+	case JobKindLink:
+		return j.Link != nil
+	default:
+		return false
+	}
+}
+
+func (m Message) toContent() api.MessageContent {
+	attachments := make([]model.AttachmentSource, 0, len(m.Attachments))
+	for _, path := range m.Attachments {
+		attachments = append(attachments, model.NewStringAttachment(path))
+	}
+
+	return api.MessageContent{
+		Msg:         m.Msg,
+		Style:       m.Style,
+		Urgency:     m.Urgency,
+		Quote:       m.Quote,
+		Mentions:    m.Mentions,
+		Attachments: attachments,
+		TTL:         m.TTL,
+	}
+}
+
+func (l Link) toOptions() api.SendLinkOptions {
+	return api.SendLinkOptions{
+		Msg:      l.Msg,
+		Link:     l.Link,
+		TTL:      l.TTL,
+		Mentions: l.Mentions,
+	}
+}
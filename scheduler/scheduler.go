@@ -0,0 +1,325 @@
+// Package scheduler sends messages, stickers and links at a later time,
+// keeping pending jobs in a pluggable Store so they survive restarts.
+package scheduler
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Amrakk/zcago/api"
+	"github.com/Amrakk/zcago/errs"
+	"github.com/Amrakk/zcago/internal/timex"
+	"github.com/Amrakk/zcago/model"
+)
+
+var (
+	ErrInvalidJob     = errs.NewZCA("job is missing thread, time or payload", "scheduler.Schedule")
+	ErrJobNotFound    = errs.NewZCA("job not found", "scheduler.Cancel")
+	ErrJobRunning     = errs.NewZCA("job is already running", "scheduler.Cancel")
+	ErrAlreadyStarted = errs.NewZCA("scheduler already started", "scheduler.Start")
+	ErrNotStarted     = errs.NewZCA("scheduler not started", "scheduler.Schedule")
+)
+
+// Sender is the subset of zcago.API used to deliver jobs.
+type Sender interface {
+	SendMessage(ctx context.Context, threadID string, threadType model.ThreadType, message api.MessageContent) (*api.SendMessageResponse, error)
+	SendSticker(ctx context.Context, threadID string, threadType model.ThreadType, sticker api.SendStickerPayload) (*api.SendStickerResponse, error)
+	SendLink(ctx context.Context, threadID string, threadType model.ThreadType, options api.SendLinkOptions) (*api.SendLinkResponse, error)
+}
+
+// MissedPolicy decides what happens to jobs that became due while the scheduler was stopped.
+type MissedPolicy uint8
+
+const (
+	MissedCatchUp MissedPolicy = iota // Run missed jobs immediately after Start
+	MissedSkip                        // Drop missed jobs and report them as skipped
+)
+
+type Options struct {
+	Store        Store         // Job store, defaults to a MemoryStore
+	MissedPolicy MissedPolicy  // Policy for jobs missed while stopped, defaults to MissedCatchUp
+	MissedGrace  time.Duration // With MissedCatchUp, jobs overdue by more than this are skipped; 0 means no limit
+	ResultBuffer int           // Buffer size of the Results channel, defaults to 16
+}
+
+type jobTimer struct {
+	stop func()
+}
+
+type Scheduler struct {
+	mu sync.Mutex
+
+	sender Sender
+	opts   Options
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timers  map[string]*jobTimer
+	running map[string]struct{} // IDs of the jobs being sent
+	results chan Result
+	wg      sync.WaitGroup
+}
+
+func New(sender Sender, opts *Options) *Scheduler {
+	o := Options{
+		Store:        NewMemoryStore(),
+		MissedPolicy: MissedCatchUp,
+		ResultBuffer: 16,
+	}
+	if opts != nil {
+		if opts.Store != nil {
+			o.Store = opts.Store
+		}
+		if opts.ResultBuffer > 0 {
+			o.ResultBuffer = opts.ResultBuffer
+		}
+		o.MissedPolicy = opts.MissedPolicy
+		o.MissedGrace = opts.MissedGrace
+	}
+
+	return &Scheduler{
+		sender:  sender,
+		opts:    o,
+		timers:  make(map[string]*jobTimer),
+		running: make(map[string]struct{}),
+		results: make(chan Result, o.ResultBuffer),
+	}
+}
+
+// Results delivers the outcome of every executed or skipped job.
+func (s *Scheduler) Results() <-chan Result { return s.results }
+
+// Start loads the pending jobs from the store, applies the MissedPolicy
+// to overdue ones and arms timers for the rest. Jobs stop firing once ctx is done.
+func (s *Scheduler) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx != nil {
+		return ErrAlreadyStarted
+	}
+
+	jobs, err := s.opts.Store.List()
+	if err != nil {
+		return errs.WrapZCA("failed to load jobs", "scheduler.Start", err)
+	}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	runCtx := s.ctx
+
+	now := time.Now()
+	for _, job := range jobs {
+		overdue := now.Sub(job.RunAt)
+		if overdue <= 0 {
+			s.arm(job)
+			continue
+		}
+
+		skip := s.opts.MissedPolicy == MissedSkip ||
+			(s.opts.MissedGrace > 0 && overdue > s.opts.MissedGrace)
+		if skip {
+			_ = s.opts.Store.Delete(job.ID)
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.emit(runCtx, Result{Job: job, Missed: true, Skipped: true})
+			}()
+			continue
+		}
+
+		s.running[job.ID] = struct{}{}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.run(runCtx, job, true)
+		}()
+	}
+
+	return nil
+}
+
+// Stop disarms all timers, aborts running jobs and waits for them to return.
+// Pending and aborted jobs stay in the store and are reloaded by the next Start.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if s.ctx == nil {
+		s.mu.Unlock()
+		return
+	}
+	cancel := s.cancel
+	timers := s.timers
+	s.timers = make(map[string]*jobTimer)
+	s.mu.Unlock()
+
+	cancel()
+	for _, t := range timers {
+		t.stop()
+	}
+	s.wg.Wait()
+
+	s.mu.Lock()
+	s.ctx, s.cancel = nil, nil
+	s.mu.Unlock()
+}
+
+// Schedule stores the job and arms it. A new ID is generated when job.ID is empty.
+// Rescheduling the ID of a job being sent fails with ErrJobRunning.
+func (s *Scheduler) Schedule(job Job) (string, error) {
+	if !job.isValid() {
+		return "", ErrInvalidJob
+	}
+	if job.ID == "" {
+		job.ID = uuid.NewString()
+	}
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = time.Now()
+	}
+
+	s.mu.Lock()
+	if s.ctx == nil {
+		s.mu.Unlock()
+		return "", ErrNotStarted
+	}
+	if _, ok := s.running[job.ID]; ok {
+		s.mu.Unlock()
+		return "", ErrJobRunning
+	}
+	if err := s.opts.Store.Save(job); err != nil {
+		s.mu.Unlock()
+		return "", errs.WrapZCA("failed to save job", "scheduler.Schedule", err)
+	}
+
+	// Rescheduling an existing ID replaces its timer
+	prev, replaced := s.timers[job.ID]
+	s.arm(job)
+	s.mu.Unlock()
+
+	// The replaced timer no longer owns the ID, so stop only waits for it to notice
+	if replaced {
+		prev.stop()
+	}
+	return job.ID, nil
+}
+
+// Cancel removes a pending job. A job that already fired can no longer be
+// cancelled and returns ErrJobRunning.
+func (s *Scheduler) Cancel(id string) error {
+	s.mu.Lock()
+	if _, ok := s.running[id]; ok {
+		s.mu.Unlock()
+		return ErrJobRunning
+	}
+	t, ok := s.timers[id]
+	delete(s.timers, id)
+	s.mu.Unlock()
+
+	if !ok {
+		return ErrJobNotFound
+	}
+
+	t.stop()
+	if err := s.opts.Store.Delete(id); err != nil {
+		return errs.WrapZCA("failed to delete job", "scheduler.Cancel", err)
+	}
+	return nil
+}
+
+// Pending lists the jobs not yet executed, ordered by RunAt. Jobs being sent are left out.
+func (s *Scheduler) Pending() ([]Job, error) {
+	jobs, err := s.opts.Store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.DeleteFunc(jobs, func(job Job) bool {
+		_, ok := s.running[job.ID]
+		return ok
+	}), nil
+}
+
+// arm must be called with s.mu held.
+func (s *Scheduler) arm(job Job) {
+	ctx := s.ctx
+	t := &jobTimer{}
+
+	t.stop = timex.SetTimeout(ctx, time.Until(job.RunAt), func() {
+		s.mu.Lock()
+		// The timer may have been replaced by a reschedule of the same ID
+		ok := s.timers[job.ID] == t
+		if ok {
+			delete(s.timers, job.ID)
+			s.running[job.ID] = struct{}{}
+			s.wg.Add(1)
+		}
+		s.mu.Unlock()
+
+		if !ok {
+			return
+		}
+		defer s.wg.Done()
+		s.run(ctx, job, false)
+	})
+	s.timers[job.ID] = t
+}
+
+// run sends the job, the caller must have marked it as running.
+func (s *Scheduler) run(ctx context.Context, job Job, missed bool) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, job.ID)
+		s.mu.Unlock()
+	}()
+
+	res := Result{Job: job, Missed: missed}
+	res.MsgID, res.Err = s.send(ctx, job)
+
+	// Jobs aborted by Stop are kept for the next Start
+	if ctx.Err() != nil && res.Err != nil {
+		return
+	}
+
+	if err := s.opts.Store.Delete(job.ID); err != nil && res.Err == nil {
+		res.Err = errs.WrapZCA("job sent but could not be removed from store", "scheduler.run", err)
+	}
+	s.emit(ctx, res)
+}
+
+func (s *Scheduler) send(ctx context.Context, job Job) (string, error) {
+	switch job.Kind {
+	case JobKindMessage:
+		resp, err := s.sender.SendMessage(ctx, job.ThreadID, job.ThreadType, job.Message.toContent())
+		if err != nil {
+			return "", err
+		}
+		return resp.MsgID(), nil
+
+	case JobKindSticker:
+		resp, err := s.sender.SendSticker(ctx, job.ThreadID, job.ThreadType, *job.Sticker)
+		if err != nil {
+			return "", err
+		}
+		return resp.MsgID, nil
+
+	case JobKindLink:
+		resp, err := s.sender.SendLink(ctx, job.ThreadID, job.ThreadType, job.Link.toOptions())
+		if err != nil {
+			return "", err
+		}
+		return resp.MsgID, nil
+
+	default:
+		return "", ErrInvalidJob
+	}
+}
+
+func (s *Scheduler) emit(ctx context.Context, res Result) {
+	select {
+	case s.results <- res:
+	case <-ctx.Done():
+	}
+}
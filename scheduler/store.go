@@ -0,0 +1,155 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/Amrakk/zcago/errs"
+)
+
+// Store persists pending jobs. Implementations must be safe for concurrent use.
+type Store interface {
+	Save(job Job) error
+	Delete(id string) error
+	List() ([]Job, error)
+}
+
+type MemoryStore struct {
+	mu   sync.Mutex
+	jobs map[string]Job
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: make(map[string]Job)}
+}
+
+func (s *MemoryStore) Save(job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.ID] = job
+	return nil
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+	return nil
+}
+
+func (s *MemoryStore) List() ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedJobs(s.jobs), nil
+}
+
+// FileStore keeps jobs in a single JSON file, rewritten atomically on every change.
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+	jobs map[string]Job
+}
+
+var _ Store = (*FileStore)(nil)
+
+// NewFileStore opens the JSON job file at path, creating it on the first write if missing.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, jobs: make(map[string]Job)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, errs.WrapZCA("failed to read job file", "scheduler.NewFileStore", err)
+	}
+	if len(data) == 0 {
+		return s, nil
+	}
+
+	var jobs []Job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return nil, errs.WrapZCA("failed to parse job file", "scheduler.NewFileStore", err)
+	}
+	for _, j := range jobs {
+		s.jobs[j.ID] = j
+	}
+
+	return s, nil
+}
+
+func (s *FileStore) Save(job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, existed := s.jobs[job.ID]
+	s.jobs[job.ID] = job
+	if err := s.flush(); err != nil {
+		if existed {
+			s.jobs[job.ID] = prev
+		} else {
+			delete(s.jobs, job.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[id]; !ok {
+		return nil
+	}
+	delete(s.jobs, id)
+	return s.flush()
+}
+
+func (s *FileStore) List() ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedJobs(s.jobs), nil
+}
+
+func (s *FileStore) flush() error {
+	data, err := json.MarshalIndent(sortedJobs(s.jobs), "", "  ")
+	if err != nil {
+		return errs.WrapZCA("failed to encode jobs", "scheduler.FileStore", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return errs.WrapZCA("failed to create temp file", "scheduler.FileStore", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return errs.WrapZCA("failed to write job file", "scheduler.FileStore", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return errs.WrapZCA("failed to write job file", "scheduler.FileStore", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return errs.WrapZCA("failed to replace job file", "scheduler.FileStore", err)
+	}
+	return nil
+}
+
+func sortedJobs(m map[string]Job) []Job {
+	jobs := make([]Job, 0, len(m))
+	for _, j := range m {
+		jobs = append(jobs, j)
+	}
+	slices.SortFunc(jobs, func(a, b Job) int {
+		return a.RunAt.Compare(b.RunAt)
+	})
+	return jobs
+}
@@ -29,6 +29,7 @@ type Context interface {
 	Settings() *Settings
 	ExtraVer() *ExtraVer
 	UploadCallback() *CallbacksMap
+	SendLimiter() *SendLimiter
 
 	ZPWWebsocket() []string
 	WSPingInterval() time.Duration
@@ -34,6 +34,7 @@ type contextImpl struct {
 	jar       http.CookieJar
 
 	uploadCallbacks *CallbacksMap
+	sendLimiter     *SendLimiter
 }
 
 func newContextImpl(optFns ...Option) *contextImpl {
@@ -64,9 +65,11 @@ func newContextImpl(optFns ...Option) *contextImpl {
 			APIVersion:          cfg.apiVersion,
 			Client:              cfg.client,
 			ImageMetadataGetter: cfg.imageMetadataGetter,
+			SendInterval:        cfg.sendInterval,
 		},
 		jar:             jar,
 		uploadCallbacks: NewCallbacksMap(),
+		sendLimiter:     NewSendLimiter(cfg.sendInterval),
 		language:        config.DefaultLanguage,
 	}
 }
@@ -185,6 +188,8 @@ func (c *contextImpl) UploadCallback() *CallbacksMap {
 	return c.uploadCallbacks
 }
 
+func (c *contextImpl) SendLimiter() *SendLimiter { return c.sendLimiter }
+
 func (c *contextImpl) ZPWServiceMap() *ZpwServiceMap {
 	if c.loginInfo == nil {
 		return nil
@@ -0,0 +1,51 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SendLimiter spaces out the messages sent by a session, it is shared by every
+// API sending messages so that concurrent senders stay under one rate.
+type SendLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// NewSendLimiter returns a limiter allowing one send per interval, an interval
+// of zero or less disables it.
+func NewSendLimiter(interval time.Duration) *SendLimiter {
+	return &SendLimiter{interval: interval}
+}
+
+// Wait blocks until the next send is allowed or ctx is done.
+func (l *SendLimiter) Wait(ctx context.Context) error {
+	if l == nil || l.interval <= 0 {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	d := time.Until(at)
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
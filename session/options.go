@@ -2,6 +2,7 @@ package session
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/Amrakk/zcago/config"
 	"github.com/Amrakk/zcago/model"
@@ -18,6 +19,7 @@ type OptionsSnapshot struct {
 	APIVersion          uint
 	Client              *http.Client
 	ImageMetadataGetter ImageMetadataGetter
+	SendInterval        time.Duration
 }
 
 type options struct {
@@ -31,6 +33,8 @@ type options struct {
 	client *http.Client
 
 	imageMetadataGetter ImageMetadataGetter
+
+	sendInterval time.Duration
 }
 
 func WithSelfListen(v bool) Option         { return func(o *options) { o.selfListen = v } }
@@ -59,6 +63,12 @@ func WithImageMetadataGetter(f ImageMetadataGetter) Option {
 	return func(o *options) { o.imageMetadataGetter = f }
 }
 
+// WithSendInterval sets the minimum delay between two messages sent by the session,
+// across every caller. Messages are not spaced out by default.
+func WithSendInterval(d time.Duration) Option {
+	return func(o *options) { o.sendInterval = d }
+}
+
 func defaultOptions() options {
 	return options{
 		selfListen:  false,